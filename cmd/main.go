@@ -2,18 +2,23 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/iskhakmuhamad/todo-api/internal/config"
 	"github.com/iskhakmuhamad/todo-api/internal/handler"
+	"github.com/iskhakmuhamad/todo-api/internal/loginguard"
+	"github.com/iskhakmuhamad/todo-api/internal/mailer"
 	"github.com/iskhakmuhamad/todo-api/internal/middleware"
 	"github.com/iskhakmuhamad/todo-api/internal/repository"
 	"github.com/iskhakmuhamad/todo-api/internal/routes"
 	"github.com/iskhakmuhamad/todo-api/internal/seeder"
 	"github.com/iskhakmuhamad/todo-api/internal/service"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 )
 
 func main() {
@@ -36,39 +41,116 @@ func main() {
 		}
 	}
 
+	// Load JWT signing keys
+	jwtKeyFiles, err := utils.ParseKeyFiles(cfg.JWTKeys)
+	if err != nil {
+		log.Fatal("Invalid JWT_KEYS: ", err)
+	}
+	jwtKeys, err := utils.LoadKeySet(utils.KeySetConfig{
+		Algorithm:   cfg.JWTAlgorithm,
+		Secret:      cfg.JWTSecret,
+		KeyFiles:    jwtKeyFiles,
+		ActiveKeyID: cfg.JWTActiveKeyID,
+		Issuer:      cfg.JWTIssuer,
+		Audience:    cfg.JWTAudience,
+	})
+	if err != nil {
+		log.Fatal("Failed to load JWT keys: ", err)
+	}
+	if cfg.JWTAlgorithm == "HS256" && cfg.JWTSecret == "your-secret-key" {
+		log.Println("WARNING: JWT_SECRET is set to the default value, change it before deploying")
+	}
+
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	todoRepo := repository.NewTodoRepository(db)
 	categoryRepo := repository.NewCategoryRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	userTokenRepo := repository.NewUserTokenRepository(db)
+	recoveryCodeRepo := repository.NewRecoveryCodeRepository(db)
+	accessTokenRepo := repository.NewAccessTokenRepository(db)
+	identityRepo := repository.NewIdentityRepository(db)
+	exportRepo := repository.NewExportRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	tagRepo := repository.NewTagRepository(db)
+
+	// Initialize mailer
+	mail := mailer.New(cfg)
+
+	// Initialize login guard
+	lockout := time.Duration(cfg.LoginLockoutMinutes) * time.Minute
+	attemptStore := loginguard.NewMemoryStore(lockout)
+	if cfg.LoginAttemptStore == "postgres" {
+		attemptStore = loginguard.NewPostgresStore(db, lockout)
+	}
+	loginGuard := loginguard.New(attemptStore, loginguard.Config{
+		MaxAttempts:   cfg.LoginMaxAttempts,
+		IPMaxAttempts: cfg.LoginIPMaxAttempts,
+		Lockout:       lockout,
+		BackoffBase:   time.Duration(cfg.LoginBackoffBaseSeconds) * time.Second,
+	})
+
+	// Throttle verification emails per address and IP
+	resendInterval := time.Duration(cfg.EmailVerificationResendSeconds) * time.Second
+	resendStore := loginguard.NewMemoryStore(resendInterval)
+	if cfg.LoginAttemptStore == "postgres" {
+		resendStore = loginguard.NewPostgresStore(db, resendInterval)
+	}
+	resendThrottle := loginguard.NewThrottle(resendStore, "verification-resend:", resendInterval)
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, cfg.JWTSecret)
-	todoService := service.NewTodoService(todoRepo)
+	mfaService := service.NewMFAService(userRepo, recoveryCodeRepo, cfg)
+	authService := service.NewAuthService(userRepo, sessionRepo, userTokenRepo, accessTokenRepo, mfaService, loginGuard, resendThrottle, mail, jwtKeys, cfg)
+	todoService := service.NewTodoService(todoRepo, categoryRepo, tagRepo, cfg)
 	categoryService := service.NewCategoryService(categoryRepo)
+	accessTokenService := service.NewAccessTokenService(accessTokenRepo)
+	adminService := service.NewAdminService(userRepo, todoRepo, sessionRepo, authService, loginGuard)
+	oidcService := service.NewOIDCService(userRepo, identityRepo, authService, cfg)
+	exportService := service.NewExportService(exportRepo, cfg)
+	accountService := service.NewAccountService(userRepo, authService, exportService)
+	trashService := service.NewTrashService(todoRepo, categoryRepo, cfg)
+	tagService := service.NewTagService(tagRepo)
+
+	// Start background workers
+	exportService.Start()
+	trashService.Start()
 
 	// Initialize handlers
 	authHandler := handler.NewAuthHandler(authService)
 	todoHandler := handler.NewTodoHandler(todoService)
 	categoryHandler := handler.NewCategoryHandler(categoryService)
+	mfaHandler := handler.NewMFAHandler(mfaService)
+	accessTokenHandler := handler.NewAccessTokenHandler(accessTokenService)
+	adminHandler := handler.NewAdminHandler(adminService)
+	oidcHandler := handler.NewOIDCHandler(oidcService)
+	jwksHandler := handler.NewJWKSHandler(jwtKeys)
+	accountHandler := handler.NewAccountHandler(accountService)
+	exportHandler := handler.NewExportHandler(exportService)
+	trashHandler := handler.NewTrashHandler(trashService)
+	tagHandler := handler.NewTagHandler(tagService)
 
 	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(cfg.JWTSecret)
+	authMiddleware := middleware.NewAuthMiddleware(cfg, jwtKeys, sessionRepo, accessTokenRepo)
+	idempotencyMiddleware := middleware.NewIdempotencyMiddleware(idempotencyRepo, time.Duration(cfg.IdempotencyKeyTTLHours)*time.Hour)
+	idempotencyMiddleware.Start()
 
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		},
+		ErrorHandler: handler.ErrorHandler,
 	})
 
 	// Global middleware
-	app.Use(logger.New())
-	app.Use(cors.New())
+	app.Use(requestid.New())
+	app.Use(logger.New(logger.Config{
+		Format: "${time} | ${status} | ${latency} | ${ip} | ${method} | ${path} | ${locals:requestid} | ${error}\n",
+	}))
+	app.Use(cors.New(cors.Config{
+		// Browsers need this to read ETag for If-Match
+		ExposeHeaders: fiber.HeaderETag,
+	}))
 
 	// Setup routes
-	routes.SetupRoutes(app, authHandler, todoHandler, categoryHandler, authMiddleware)
+	routes.SetupRoutes(app, authHandler, todoHandler, categoryHandler, mfaHandler, accessTokenHandler, adminHandler, oidcHandler, jwksHandler, accountHandler, exportHandler, trashHandler, tagHandler, authMiddleware, idempotencyMiddleware)
 
 	// Start server
 	log.Printf("Server starting on port %s", cfg.Port)
@@ -0,0 +1,91 @@
+// Package apperror defines the errors services return to describe why a
+// request could not be served. Each error carries a kind, which decides the
+// HTTP status, and a stable code clients can switch on.
+package apperror
+
+import (
+	"time"
+)
+
+type Kind string
+
+const (
+	KindBadRequest         Kind = "bad_request"
+	KindUnauthorized       Kind = "unauthorized"
+	KindForbidden          Kind = "forbidden"
+	KindNotFound           Kind = "not_found"
+	KindConflict           Kind = "conflict"
+	KindPreconditionFailed Kind = "precondition_failed"
+	KindValidation         Kind = "validation"
+	KindTooManyRequests    Kind = "too_many_requests"
+	KindServiceUnavailable Kind = "service_unavailable"
+)
+
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+
+	// RetryAfter is sent as the Retry-After header when set
+	RetryAfter time.Duration
+
+	// Err is the underlying cause, if any. It is never shown to clients.
+	Err error
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func BadRequest(code, message string) *Error {
+	return New(KindBadRequest, code, message)
+}
+
+func Unauthorized(code, message string) *Error {
+	return New(KindUnauthorized, code, message)
+}
+
+func Forbidden(code, message string) *Error {
+	return New(KindForbidden, code, message)
+}
+
+func NotFound(code, message string) *Error {
+	return New(KindNotFound, code, message)
+}
+
+func Conflict(code, message string) *Error {
+	return New(KindConflict, code, message)
+}
+
+func PreconditionFailed(code, message string) *Error {
+	return New(KindPreconditionFailed, code, message)
+}
+
+func Validation(code, message string) *Error {
+	return New(KindValidation, code, message)
+}
+
+func TooManyRequests(code, message string, retryAfter time.Duration) *Error {
+	e := New(KindTooManyRequests, code, message)
+	e.RetryAfter = retryAfter
+	return e
+}
+
+func ServiceUnavailable(code, message string) *Error {
+	return New(KindServiceUnavailable, code, message)
+}
+
+// Wrap returns a copy of e that records cause as the underlying error.
+func (e *Error) Wrap(cause error) *Error {
+	wrapped := *e
+	wrapped.Err = cause
+	return &wrapped
+}
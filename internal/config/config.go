@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/iskhakmuhamad/todo-api/internal/domain"
@@ -16,16 +17,78 @@ import (
 )
 
 type Config struct {
-	DBHost         string
-	DBPort         string
-	DBUser         string
-	DBPassword     string
-	DBName         string
-	DBSSLMode      string
-	Port           string
-	JWTSecret      string
-	JWTExpireHours int
-	RunSeeder      bool
+	DBHost     string
+	DBPort     string
+	DBUser     string
+	DBPassword string
+	DBName     string
+	DBSSLMode  string
+	Port       string
+	JWTSecret  string
+	RunSeeder  bool
+
+	AccessTokenExpireMinutes int
+	RefreshTokenExpireHours  int
+
+	AppURL                     string
+	MailDriver                 string
+	MailFrom                   string
+	MailLogFile                string
+	SMTPHost                   string
+	SMTPPort                   string
+	SMTPUsername               string
+	SMTPPassword               string
+	PasswordResetExpireMinutes int
+
+	// RequireEmailVerification is "off", "login" (unverified users cannot log
+	// in) or "write" (unverified users get read-only access)
+	RequireEmailVerification       string
+	EmailVerificationExpireHours   int
+	EmailVerificationResendSeconds int
+
+	MFAIssuer             string
+	MFATokenExpireMinutes int
+
+	// OIDC login is enabled when OIDCIssuerURL is set
+	OIDCProvider     string
+	OIDCIssuerURL    string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       []string
+
+	// JWTAlgorithm is HS256 (signed with JWTSecret), RS256 or EdDSA. The
+	// asymmetric algorithms read keys from JWTKeys ("kid=path,kid=path").
+	JWTAlgorithm   string
+	JWTKeys        string
+	JWTActiveKeyID string
+	JWTIssuer      string
+	JWTAudience    string
+
+	// LoginAttemptStore is "memory" for a single instance or "postgres" to
+	// share failed login counters between replicas
+	LoginAttemptStore       string
+	LoginMaxAttempts        int
+	LoginIPMaxAttempts      int
+	LoginLockoutMinutes     int
+	LoginBackoffBaseSeconds int
+
+	// Personal data export archives are written to ExportDir and removed
+	// after ExportRetentionHours
+	ExportDir            string
+	ExportRetentionHours int
+
+	// Responses to requests sent with an Idempotency-Key are replayed for
+	// IdempotencyKeyTTLHours
+	IdempotencyKeyTTLHours int
+
+	// Deleted todos and categories stay in the trash for TrashRetentionDays
+	// before they are purged. 0 keeps them until purged by hand.
+	TrashRetentionDays int
+
+	// SubtaskAutoComplete marks a todo done once all its subtasks are done,
+	// and reopens it when a subtask is reopened or added
+	SubtaskAutoComplete bool
 }
 
 func Load() *Config {
@@ -34,20 +97,80 @@ func Load() *Config {
 		log.Println("No .env file found, using environment variables")
 	}
 
-	jwtExpire, _ := strconv.Atoi(getEnv("JWT_EXPIRE_HOURS", "24"))
 	runSeeder, _ := strconv.ParseBool(getEnv("RUN_SEEDER", "false"))
+	accessExpire, _ := strconv.Atoi(getEnv("ACCESS_TOKEN_EXPIRE_MINUTES", "15"))
+	refreshExpire, _ := strconv.Atoi(getEnv("REFRESH_TOKEN_EXPIRE_HOURS", "720"))
+	resetExpire, _ := strconv.Atoi(getEnv("PASSWORD_RESET_EXPIRE_MINUTES", "60"))
+	verifyExpire, _ := strconv.Atoi(getEnv("EMAIL_VERIFICATION_EXPIRE_HOURS", "48"))
+	verifyResend, _ := strconv.Atoi(getEnv("EMAIL_VERIFICATION_RESEND_SECONDS", "60"))
+	mfaExpire, _ := strconv.Atoi(getEnv("MFA_TOKEN_EXPIRE_MINUTES", "5"))
+	loginMaxAttempts, _ := strconv.Atoi(getEnv("LOGIN_MAX_ATTEMPTS", "5"))
+	loginIPMaxAttempts, _ := strconv.Atoi(getEnv("LOGIN_IP_MAX_ATTEMPTS", "20"))
+	loginLockout, _ := strconv.Atoi(getEnv("LOGIN_LOCKOUT_MINUTES", "15"))
+	loginBackoff, _ := strconv.Atoi(getEnv("LOGIN_BACKOFF_BASE_SECONDS", "1"))
+	exportRetention, _ := strconv.Atoi(getEnv("EXPORT_RETENTION_HOURS", "24"))
+	idempotencyTTL, _ := strconv.Atoi(getEnv("IDEMPOTENCY_KEY_TTL_HOURS", "24"))
+	trashRetention, _ := strconv.Atoi(getEnv("TRASH_RETENTION_DAYS", "30"))
+	subtaskAutoComplete, _ := strconv.ParseBool(getEnv("SUBTASK_AUTO_COMPLETE", "false"))
 
 	return &Config{
-		DBHost:         getEnv("DB_HOST", "localhost"),
-		DBPort:         getEnv("DB_PORT", "5432"),
-		DBUser:         getEnv("DB_USER", "postgres"),
-		DBPassword:     getEnv("DB_PASSWORD", "postgres"),
-		DBName:         getEnv("DB_NAME", "todoapp"),
-		DBSSLMode:      getEnv("DB_SSLMODE", "disable"),
-		Port:           getEnv("PORT", "3000"),
-		JWTSecret:      getEnv("JWT_SECRET", "your-secret-key"),
-		JWTExpireHours: jwtExpire,
-		RunSeeder:      runSeeder,
+		DBHost:     getEnv("DB_HOST", "localhost"),
+		DBPort:     getEnv("DB_PORT", "5432"),
+		DBUser:     getEnv("DB_USER", "postgres"),
+		DBPassword: getEnv("DB_PASSWORD", "postgres"),
+		DBName:     getEnv("DB_NAME", "todoapp"),
+		DBSSLMode:  getEnv("DB_SSLMODE", "disable"),
+		Port:       getEnv("PORT", "3000"),
+		JWTSecret:  getEnv("JWT_SECRET", "your-secret-key"),
+		RunSeeder:  runSeeder,
+
+		AccessTokenExpireMinutes: accessExpire,
+		RefreshTokenExpireHours:  refreshExpire,
+
+		AppURL:                     getEnv("APP_URL", "http://localhost:3000"),
+		MailDriver:                 getEnv("MAIL_DRIVER", "log"),
+		MailFrom:                   getEnv("MAIL_FROM", "no-reply@todo-api.local"),
+		MailLogFile:                getEnv("MAIL_LOG_FILE", ""),
+		SMTPHost:                   getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:                   getEnv("SMTP_PORT", "1025"),
+		SMTPUsername:               getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:               getEnv("SMTP_PASSWORD", ""),
+		PasswordResetExpireMinutes: resetExpire,
+
+		RequireEmailVerification:       getEnv("REQUIRE_EMAIL_VERIFICATION", "off"),
+		EmailVerificationExpireHours:   verifyExpire,
+		EmailVerificationResendSeconds: verifyResend,
+
+		MFAIssuer:             getEnv("MFA_ISSUER", "Todo API"),
+		MFATokenExpireMinutes: mfaExpire,
+
+		OIDCProvider:     getEnv("OIDC_PROVIDER", "company"),
+		OIDCIssuerURL:    getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:  getEnv("OIDC_REDIRECT_URL", "http://localhost:3000/api/v1/auth/oidc/callback"),
+		OIDCScopes:       strings.Fields(getEnv("OIDC_SCOPES", "openid email profile")),
+
+		JWTAlgorithm:   getEnv("JWT_ALGORITHM", "HS256"),
+		JWTKeys:        getEnv("JWT_KEYS", ""),
+		JWTActiveKeyID: getEnv("JWT_ACTIVE_KEY_ID", ""),
+		JWTIssuer:      getEnv("JWT_ISSUER", "todo-api"),
+		JWTAudience:    getEnv("JWT_AUDIENCE", "todo-api"),
+
+		LoginAttemptStore:       getEnv("LOGIN_ATTEMPT_STORE", "memory"),
+		LoginMaxAttempts:        loginMaxAttempts,
+		LoginIPMaxAttempts:      loginIPMaxAttempts,
+		LoginLockoutMinutes:     loginLockout,
+		LoginBackoffBaseSeconds: loginBackoff,
+
+		ExportDir:            getEnv("EXPORT_DIR", "./exports"),
+		ExportRetentionHours: exportRetention,
+
+		IdempotencyKeyTTLHours: idempotencyTTL,
+
+		TrashRetentionDays: trashRetention,
+
+		SubtaskAutoComplete: subtaskAutoComplete,
 	}
 }
 
@@ -81,7 +204,19 @@ func AutoMigrate(db *gorm.DB) {
 	err := db.AutoMigrate(
 		&domain.User{},
 		&domain.Category{},
+		&domain.Tag{},
 		&domain.Todo{},
+		&domain.TodoDependency{},
+		&domain.Session{},
+		&domain.RefreshToken{},
+		&domain.UserToken{},
+		&domain.RecoveryCode{},
+		&domain.PersonalAccessToken{},
+		&domain.ExternalIdentity{},
+		&domain.OAuthState{},
+		&domain.LoginAttempt{},
+		&domain.ExportJob{},
+		&domain.IdempotencyKey{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"time"
+)
+
+const (
+	// AccessTokenPrefix lets the auth middleware tell personal access tokens
+	// apart from JWTs without trying to parse them.
+	AccessTokenPrefix = "tdp_"
+
+	ScopeTodosRead       = "todos:read"
+	ScopeTodosWrite      = "todos:write"
+	ScopeCategoriesRead  = "categories:read"
+	ScopeCategoriesWrite = "categories:write"
+)
+
+var AccessTokenScopes = []string{
+	ScopeTodosRead,
+	ScopeTodosWrite,
+	ScopeCategoriesRead,
+	ScopeCategoriesWrite,
+}
+
+type PersonalAccessToken struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	Name        string     `json:"name" gorm:"not null"`
+	TokenPrefix string     `json:"token_prefix"`
+	TokenHash   string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scopes      []string   `json:"scopes" gorm:"serializer:json"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	// Relations
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+type CreateAccessTokenRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=todos:read todos:write categories:read categories:write"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type CreateAccessTokenResponse struct {
+	Token       string              `json:"token"`
+	AccessToken PersonalAccessToken `json:"access_token"`
+}
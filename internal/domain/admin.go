@@ -0,0 +1,26 @@
+package domain
+
+type UserFilter struct {
+	Query  string `json:"q"`
+	Role   Role   `json:"role"`
+	Status string `json:"status"`
+	Page   int    `json:"page"`
+	Limit  int    `json:"limit"`
+}
+
+type TodoCounts struct {
+	Total int64 `json:"total"`
+	Done  int64 `json:"done"`
+	Open  int64 `json:"open"`
+}
+
+// AdminUser is a user as seen through the admin API, including how many todos
+// they own.
+type AdminUser struct {
+	User
+	TodoCounts TodoCounts `json:"todo_counts"`
+}
+
+type UpdateRoleRequest struct {
+	Role Role `json:"role" validate:"required,oneof=user admin"`
+}
@@ -12,6 +12,7 @@ type Category struct {
 	Name        string         `json:"name" gorm:"not null"`
 	Description string         `json:"description"`
 	Color       string         `json:"color" gorm:"default:#3B82F6"`
+	Version     uint           `json:"version" gorm:"not null;default:1"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
@@ -22,13 +23,53 @@ type Category struct {
 }
 
 type CreateCategoryRequest struct {
-	Name        string `json:"name" validate:"required"`
+	Name        string `json:"name" validate:"required,max=100"`
 	Description string `json:"description"`
-	Color       string `json:"color"`
+	Color       string `json:"color" validate:"omitempty,hexcolor"`
 }
 
-type UpdateCategoryRequest struct {
-	Name        string `json:"name"`
+// ReplaceCategoryRequest is the body of PUT /categories/:id. It replaces the
+// whole category; an empty color falls back to the default.
+type ReplaceCategoryRequest struct {
+	Name        string `json:"name" validate:"required,max=100"`
 	Description string `json:"description"`
-	Color       string `json:"color"`
+	Color       string `json:"color" validate:"omitempty,hexcolor"`
+}
+
+// CategoryPatch is the body of PATCH /categories/:id, a JSON merge patch.
+type CategoryPatch struct {
+	Name        Optional[string] `json:"name"`
+	Description Optional[string] `json:"description"`
+	Color       Optional[string] `json:"color"`
+}
+
+func (p CategoryPatch) Apply(req *ReplaceCategoryRequest) {
+	p.Name.Apply(&req.Name)
+	p.Description.Apply(&req.Description)
+	p.Color.Apply(&req.Color)
+}
+
+// CategoryDeleteStrategy decides what happens to a category's todos when the
+// category is deleted.
+type CategoryDeleteStrategy string
+
+const (
+	// DeleteStrategyDetach keeps the todos without a category
+	DeleteStrategyDetach CategoryDeleteStrategy = "detach"
+	// DeleteStrategyMove files the todos under another category
+	DeleteStrategyMove CategoryDeleteStrategy = "move"
+	// DeleteStrategyDelete moves the todos to the trash with the category
+	DeleteStrategyDelete CategoryDeleteStrategy = "delete"
+)
+
+// DeleteCategoryRequest is read from the query string of
+// DELETE /categories/:id.
+type DeleteCategoryRequest struct {
+	Strategy CategoryDeleteStrategy `json:"strategy" validate:"omitempty,oneof=detach move delete"`
+	TargetID *uint                  `json:"target_id"`
+}
+
+type DeleteCategoryResult struct {
+	Strategy      CategoryDeleteStrategy `json:"strategy"`
+	TodosAffected int64                  `json:"todos_affected"`
 }
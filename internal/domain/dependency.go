@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"time"
+)
+
+// TodoDependency records that TodoID cannot be done before BlockerID is.
+// Both todos belong to the same user, and the dependencies of a user never
+// form a cycle.
+type TodoDependency struct {
+	TodoID    uint      `json:"todo_id" gorm:"primaryKey"`
+	BlockerID uint      `json:"blocker_id" gorm:"primaryKey;index"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relations
+	Todo    Todo `json:"-" gorm:"foreignKey:TodoID;constraint:OnDelete:CASCADE"`
+	Blocker Todo `json:"-" gorm:"foreignKey:BlockerID;constraint:OnDelete:CASCADE"`
+}
+
+// AddDependencyRequest is the body of POST /todos/:id/dependencies.
+type AddDependencyRequest struct {
+	BlockerID uint `json:"blocker_id" validate:"required"`
+}
+
+// TodoDependencies lists the todos a todo waits for and the todos waiting for
+// it. Todos in the trash are left out.
+type TodoDependencies struct {
+	BlockedBy []Todo `json:"blocked_by"`
+	Blocking  []Todo `json:"blocking"`
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"time"
+)
+
+type ExportStatus string
+
+const (
+	ExportStatusPending   ExportStatus = "pending"
+	ExportStatusRunning   ExportStatus = "running"
+	ExportStatusCompleted ExportStatus = "completed"
+	ExportStatusFailed    ExportStatus = "failed"
+	ExportStatusExpired   ExportStatus = "expired"
+)
+
+// ExportJob tracks the background build of a personal data archive.
+type ExportJob struct {
+	ID          uint         `json:"id" gorm:"primaryKey"`
+	UserID      uint         `json:"user_id" gorm:"not null;index"`
+	Status      ExportStatus `json:"status" gorm:"not null;index"`
+	FilePath    string       `json:"-"`
+	FileSize    int64        `json:"file_size"`
+	Error       string       `json:"error,omitempty"`
+	CompletedAt *time.Time   `json:"completed_at"`
+	ExpiresAt   *time.Time   `json:"expires_at"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// UserData is everything stored about a user, as written to an export.
+type UserData struct {
+	Profile            User
+	Categories         []Category
+	Tags               []Tag
+	Todos              []Todo
+	Dependencies       []TodoDependency
+	Sessions           []Session
+	AccessTokens       []PersonalAccessToken
+	ExternalIdentities []ExternalIdentity
+}
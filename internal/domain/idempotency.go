@@ -0,0 +1,21 @@
+package domain
+
+import (
+	"time"
+)
+
+// IdempotencyKey remembers the response to a POST or PATCH sent with an
+// Idempotency-Key header so a retry gets the same answer instead of running
+// the request again. StatusCode is 0 while the first request is in flight.
+type IdempotencyKey struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	Key         string    `json:"key" gorm:"not null;size:255;uniqueIndex:idx_idempotency_user_key"`
+	RequestHash string    `json:"-" gorm:"not null"`
+	StatusCode  int       `json:"status_code" gorm:"not null;default:0"`
+	ContentType string    `json:"-"`
+	ETag        string    `json:"-" gorm:"column:etag"`
+	Body        []byte    `json:"-"`
+	ExpiresAt   time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt   time.Time `json:"created_at"`
+}
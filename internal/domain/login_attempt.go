@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"time"
+)
+
+// LoginAttempt counts recent failed logins for one key (an account or a
+// client IP). It backs the Postgres login guard store.
+type LoginAttempt struct {
+	AttemptKey    string    `json:"attempt_key" gorm:"primaryKey"`
+	Failures      int       `json:"failures" gorm:"not null"`
+	LastFailureAt time.Time `json:"last_failure_at" gorm:"not null;index"`
+}
+
+type UnlockAccountRequest struct {
+	Token string `json:"token" validate:"required"`
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"encoding/json"
+)
+
+// Optional is a field of a JSON merge patch (RFC 7396). It tells apart a
+// member that is missing (leave unchanged), null (clear) and a new value.
+type Optional[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+// Some returns an Optional holding value.
+func Some[T any](value T) Optional[T] {
+	return Optional[T]{Set: true, Value: value}
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// Apply merges the patch member into dst. null resets dst to its zero value.
+func (o Optional[T]) Apply(dst *T) {
+	if !o.Set {
+		return
+	}
+	if o.Null {
+		var zero T
+		*dst = zero
+		return
+	}
+	*dst = o.Value
+}
+
+// ApplyPtr is Apply for nullable fields stored as pointers.
+func (o Optional[T]) ApplyPtr(dst **T) {
+	if !o.Set {
+		return
+	}
+	if o.Null {
+		*dst = nil
+		return
+	}
+	value := o.Value
+	*dst = &value
+}
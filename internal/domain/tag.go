@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"time"
+)
+
+// Tag is a label a user can put on any number of todos, across categories.
+// Names are unique per user, ignoring case.
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_tag_user_lower_name,priority:1"`
+	Name      string    `json:"name" gorm:"not null;uniqueIndex:idx_tag_user_lower_name,expression:LOWER(name),priority:2"`
+	Color     string    `json:"color" gorm:"default:#6B7280"`
+	Version   uint      `json:"version" gorm:"not null;default:1"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// TodoCount is only filled in when listing tags
+	TodoCount *int64 `json:"todo_count,omitempty" gorm:"->;-:migration"`
+}
+
+// TodoTag is a row of the todo_tags join table.
+type TodoTag struct {
+	TodoID uint `gorm:"primaryKey"`
+	TagID  uint `gorm:"primaryKey"`
+}
+
+type CreateTagRequest struct {
+	Name  string `json:"name" validate:"required,max=50"`
+	Color string `json:"color" validate:"omitempty,hexcolor"`
+}
+
+// ReplaceTagRequest is the body of PUT /tags/:id. An empty color falls back
+// to the default.
+type ReplaceTagRequest struct {
+	Name  string `json:"name" validate:"required,max=50"`
+	Color string `json:"color" validate:"omitempty,hexcolor"`
+}
+
+// TagPatch is the body of PATCH /tags/:id, a JSON merge patch. Changing the
+// name renames the tag on every todo that has it.
+type TagPatch struct {
+	Name  Optional[string] `json:"name"`
+	Color Optional[string] `json:"color"`
+}
+
+func (p TagPatch) Apply(req *ReplaceTagRequest) {
+	p.Name.Apply(&req.Name)
+	p.Color.Apply(&req.Color)
+}
+
+// MergeTagRequest is the body of POST /tags/:id/merge. The tag in the path is
+// merged into TargetID and then deleted.
+type MergeTagRequest struct {
+	TargetID uint `json:"target_id" validate:"required"`
+}
+
+type MergeTagResult struct {
+	Tag           *Tag  `json:"tag"`
+	TodosAffected int64 `json:"todos_affected"`
+}
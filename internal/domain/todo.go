@@ -22,42 +22,178 @@ type Todo struct {
 	ID          uint           `json:"id" gorm:"primaryKey"`
 	UserID      uint           `json:"user_id" gorm:"not null;index"`
 	CategoryID  *uint          `json:"category_id" gorm:"index"`
+	ParentID    *uint          `json:"parent_id" gorm:"index"`
+	Position    int            `json:"position" gorm:"not null;default:0"`
+	Rank        string         `json:"rank" gorm:"not null;default:''"`
 	Title       string         `json:"title" gorm:"not null"`
 	Description string         `json:"description"`
 	Deadline    *time.Time     `json:"deadline"`
 	Priority    Priority       `json:"priority" gorm:"default:medium"`
 	Status      Status         `json:"status" gorm:"default:todo"`
+	Recurrence  string         `json:"recurrence"`
+	SeriesID    *uint          `json:"series_id" gorm:"index"`
+	Occurrence  int            `json:"occurrence" gorm:"not null;default:1"`
+	Version     uint           `json:"version" gorm:"not null;default:1"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 
+	// Progress counts the todo's subtasks. It is nil when there are none.
+	Progress *TodoProgress `json:"progress,omitempty" gorm:"-"`
+
+	// BlockedBy lists the open todos this one is waiting for. It cannot be
+	// marked done until the list is empty.
+	BlockedBy []uint `json:"blocked_by,omitempty" gorm:"-"`
+
+	// NextOccurrence is set on a recurring todo when completing it created
+	// the next one.
+	NextOccurrence *Todo `json:"next_occurrence,omitempty" gorm:"-"`
+
 	// Relations
 	User     User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	Category *Category `json:"category,omitempty" gorm:"foreignKey:CategoryID"`
+	// Deleting a todo or tag for good removes its rows from todo_tags
+	Tags []Tag `json:"tags" gorm:"many2many:todo_tags;constraint:OnDelete:CASCADE"`
+}
+
+type TodoProgress struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+// MoveTodoRequest is the body of POST /todos/:id/move. Exactly one of
+// BeforeID and AfterID names a todo of the same category to place the todo
+// next to.
+type MoveTodoRequest struct {
+	BeforeID *uint `json:"before_id" validate:"omitempty,gt=0"`
+	AfterID  *uint `json:"after_id" validate:"omitempty,gt=0"`
+}
+
+// ReorderSubtasksRequest lists every subtask of a todo in the new order. It
+// is empty for a todo without subtasks.
+type ReorderSubtasksRequest struct {
+	IDs []uint `json:"ids" validate:"dive,gt=0"`
 }
 
 type CreateTodoRequest struct {
-	Title       string     `json:"title" validate:"required"`
+	Title       string     `json:"title" validate:"required,max=255"`
 	Description string     `json:"description"`
 	CategoryID  *uint      `json:"category_id"`
 	Deadline    *time.Time `json:"deadline"`
 	Priority    Priority   `json:"priority" validate:"omitempty,oneof=low medium high"`
+	Recurrence  string     `json:"recurrence"`
+	TagIDs      []uint     `json:"tag_ids" validate:"max=50,dive,gt=0"`
 }
 
-type UpdateTodoRequest struct {
-	Title       string     `json:"title"`
+// ReplaceTodoRequest is the body of PUT /todos/:id. It replaces the whole
+// todo, so fields that are left out are cleared.
+type ReplaceTodoRequest struct {
+	Title       string     `json:"title" validate:"required,max=255"`
 	Description string     `json:"description"`
 	CategoryID  *uint      `json:"category_id"`
 	Deadline    *time.Time `json:"deadline"`
-	Priority    Priority   `json:"priority" validate:"omitempty,oneof=low medium high"`
-	Status      Status     `json:"status" validate:"omitempty,oneof=todo done"`
+	Priority    Priority   `json:"priority" validate:"required,oneof=low medium high"`
+	Status      Status     `json:"status" validate:"required,oneof=todo done"`
+	Recurrence  string     `json:"recurrence"`
+	TagIDs      []uint     `json:"tag_ids" validate:"max=50,dive,gt=0"`
+}
+
+// TodoPatch is the body of PATCH /todos/:id, a JSON merge patch. The patched
+// todo is validated like a ReplaceTodoRequest.
+type TodoPatch struct {
+	Title       Optional[string]    `json:"title"`
+	Description Optional[string]    `json:"description"`
+	CategoryID  Optional[uint]      `json:"category_id"`
+	Deadline    Optional[time.Time] `json:"deadline"`
+	Priority    Optional[Priority]  `json:"priority"`
+	Status      Optional[Status]    `json:"status"`
+	Recurrence  Optional[string]    `json:"recurrence"`
+	TagIDs      Optional[[]uint]    `json:"tag_ids"`
+}
+
+func (p TodoPatch) Apply(req *ReplaceTodoRequest) {
+	p.Title.Apply(&req.Title)
+	p.Description.Apply(&req.Description)
+	p.CategoryID.ApplyPtr(&req.CategoryID)
+	p.Deadline.ApplyPtr(&req.Deadline)
+	p.Priority.Apply(&req.Priority)
+	p.Status.Apply(&req.Status)
+	p.Recurrence.Apply(&req.Recurrence)
+	p.TagIDs.Apply(&req.TagIDs)
 }
 
 type TodoFilter struct {
-	Status     Status   `json:"status"`
-	Priority   Priority `json:"priority"`
+	Status     Status   `json:"status" validate:"omitempty,oneof=todo done"`
+	Priority   Priority `json:"priority" validate:"omitempty,oneof=low medium high"`
 	CategoryID uint     `json:"category_id"`
-	Keyword    string   `json:"keyword"`
-	Page       int      `json:"page"`
-	Limit      int      `json:"limit"`
+	// ParentID matches the subtasks of one todo
+	ParentID uint   `json:"parent_id"`
+	Keyword  string `json:"keyword"`
+	// TagsAny matches todos with at least one of the tags, TagsAll those
+	// with every one of them and TagsNone those with none of them
+	TagsAny  []uint `json:"tags_any"`
+	TagsAll  []uint `json:"tags_all"`
+	TagsNone []uint `json:"tags_none"`
+	// Blocked matches todos that are (or, when false, are not) waiting for
+	// an open todo
+	Blocked *bool `json:"blocked"`
+	// IncludeSubtasks also matches subtasks, which are left out by default
+	IncludeSubtasks bool `json:"include_subtasks"`
+	// Sort is SortCreated (newest first, the default) or SortManual, which
+	// needs CategoryID or ParentID to pick one list
+	Sort  string `json:"sort" validate:"omitempty,oneof=created manual"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+}
+
+const (
+	SortCreated = "created"
+	SortManual  = "manual"
+)
+
+// IsEmpty reports whether the filter matches every todo.
+func (f TodoFilter) IsEmpty() bool {
+	return f.Status == "" && f.Priority == "" && f.CategoryID == 0 && f.ParentID == 0 && f.Keyword == "" &&
+		len(f.TagsAny) == 0 && len(f.TagsAll) == 0 && len(f.TagsNone) == 0 && f.Blocked == nil
+}
+
+type BulkTodoAction string
+
+const (
+	BulkMarkDone    BulkTodoAction = "mark_done"
+	BulkMarkUndone  BulkTodoAction = "mark_undone"
+	BulkSetPriority BulkTodoAction = "set_priority"
+	BulkMove        BulkTodoAction = "move"
+	BulkDelete      BulkTodoAction = "delete"
+)
+
+// BulkTodoRequest is the body of POST /todos/bulk. It selects todos either by
+// ids or by filter (paging is ignored) and applies one action to all of them.
+type BulkTodoRequest struct {
+	IDs        []uint         `json:"ids" validate:"max=1000,dive,gt=0"`
+	Filter     *TodoFilter    `json:"filter"`
+	Action     BulkTodoAction `json:"action" validate:"required,oneof=mark_done mark_undone set_priority move delete"`
+	Priority   Priority       `json:"priority" validate:"omitempty,oneof=low medium high"`
+	CategoryID *uint          `json:"category_id"`
+}
+
+const (
+	BulkResultUpdated  = "updated"
+	BulkResultDeleted  = "deleted"
+	BulkResultNotFound = "not_found"
+	// BulkResultBlocked is reported by mark_done for todos still waiting for
+	// an open todo outside the selection
+	BulkResultBlocked = "blocked"
+)
+
+type BulkTodoResult struct {
+	ID     uint   `json:"id"`
+	Result string `json:"result"`
+}
+
+type BulkTodoResponse struct {
+	Action    BulkTodoAction   `json:"action"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkTodoResult `json:"results"`
 }
@@ -0,0 +1,20 @@
+package domain
+
+import (
+	"time"
+)
+
+// DeletedTodo is a todo in the trash. PurgeAt is when it will be removed for
+// good, or nil when the trash is never emptied automatically.
+type DeletedTodo struct {
+	Todo
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   *time.Time `json:"purge_at"`
+}
+
+// DeletedCategory is a category in the trash.
+type DeletedCategory struct {
+	Category
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   *time.Time `json:"purge_at"`
+}
@@ -6,32 +6,69 @@ import (
 	"gorm.io/gorm"
 )
 
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
 type User struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Email     string         `json:"email" gorm:"uniqueIndex;not null"`
-	Username  string         `json:"username" gorm:"uniqueIndex;not null"`
-	Password  string         `json:"-" gorm:"not null"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                uint           `json:"id" gorm:"primaryKey"`
+	Email             string         `json:"email" gorm:"uniqueIndex;not null"`
+	Username          string         `json:"username" gorm:"uniqueIndex;not null"`
+	Password          string         `json:"-" gorm:"not null"`
+	Role              Role           `json:"role" gorm:"not null;default:user"`
+	DisabledAt        *time.Time     `json:"disabled_at"`
+	MustResetPassword bool           `json:"must_reset_password" gorm:"not null;default:false"`
+	EmailVerifiedAt   *time.Time     `json:"email_verified_at"`
+	TOTPSecret        string         `json:"-"`
+	TOTPEnabledAt     *time.Time     `json:"totp_enabled_at"`
+	TOTPLastStep      int64          `json:"-"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relations
-	Todos      []Todo     `json:"todos,omitempty" gorm:"foreignKey:UserID"`
-	Categories []Category `json:"categories,omitempty" gorm:"foreignKey:UserID"`
+	Todos         []Todo         `json:"todos,omitempty" gorm:"foreignKey:UserID"`
+	Categories    []Category     `json:"categories,omitempty" gorm:"foreignKey:UserID"`
+	RecoveryCodes []RecoveryCode `json:"-" gorm:"foreignKey:UserID"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Email      string `json:"email" validate:"required,email"`
+	Password   string `json:"password" validate:"required"`
+	DeviceName string `json:"device_name"`
 }
 
 type RegisterRequest struct {
 	Email    string `json:"email" validate:"required,email"`
-	Username string `json:"username" validate:"required,min=3"`
+	Username string `json:"username" validate:"required,min=3,max=50"`
 	Password string `json:"password" validate:"required,min=6"`
 }
 
+// LoginResponse carries either the issued tokens, or, when the account has
+// two-factor authentication enabled, an mfa_token to exchange at
+// /auth/login/mfa together with a TOTP or recovery code.
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string     `json:"token,omitempty"`
+	RefreshToken string     `json:"refresh_token,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	User         *User      `json:"user,omitempty"`
+	MFARequired  bool       `json:"mfa_required"`
+	MFAToken     string     `json:"mfa_token,omitempty"`
+}
+
+type UpdateProfileRequest struct {
+	Username *string `json:"username" validate:"omitempty,min=3,max=50"`
+	Email    *string `json:"email" validate:"omitempty,email"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=6"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
 }
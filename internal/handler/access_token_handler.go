@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AccessTokenHandler struct {
+	accessTokenService service.AccessTokenService
+}
+
+func NewAccessTokenHandler(accessTokenService service.AccessTokenService) *AccessTokenHandler {
+	return &AccessTokenHandler{accessTokenService: accessTokenService}
+}
+
+func (h *AccessTokenHandler) Create(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req domain.CreateAccessTokenRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	response, err := h.accessTokenService.Create(userID, req)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Access token created successfully, copy it now as it will not be shown again",
+		"data":    response,
+	})
+}
+
+func (h *AccessTokenHandler) GetAll(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	tokens, err := h.accessTokenService.GetAll(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Access tokens retrieved successfully",
+		"data":    tokens,
+	})
+}
+
+func (h *AccessTokenHandler) Revoke(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "access token")
+	if err != nil {
+		return err
+	}
+
+	if err := h.accessTokenService.Revoke(id, userID); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Access token revoked successfully",
+	})
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AccountHandler struct {
+	accountService service.AccountService
+}
+
+func NewAccountHandler(accountService service.AccountService) *AccountHandler {
+	return &AccountHandler{accountService: accountService}
+}
+
+func (h *AccountHandler) GetProfile(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	user, err := h.accountService.GetProfile(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Profile retrieved successfully",
+		"data":    user,
+	})
+}
+
+func (h *AccountHandler) UpdateProfile(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req domain.UpdateProfileRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.accountService.UpdateProfile(userID, req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Profile updated successfully",
+		"data":    user,
+	})
+}
+
+func (h *AccountHandler) ChangePassword(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	sessionID := c.Locals("sessionID").(uint)
+
+	var req domain.ChangePasswordRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.accountService.ChangePassword(userID, sessionID, req); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Password changed successfully, other sessions have been signed out",
+	})
+}
+
+func (h *AccountHandler) DeleteAccount(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req domain.DeleteAccountRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.accountService.DeleteAccount(userID, req); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Account deleted successfully",
+	})
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AdminHandler struct {
+	adminService service.AdminService
+}
+
+func NewAdminHandler(adminService service.AdminService) *AdminHandler {
+	return &AdminHandler{adminService: adminService}
+}
+
+func (h *AdminHandler) GetUsers(c *fiber.Ctx) error {
+	// Parse query parameters for filtering
+	filter := domain.UserFilter{
+		Query:  c.Query("q"),
+		Role:   domain.Role(c.Query("role")),
+		Status: c.Query("status"),
+	}
+
+	if page := c.Query("page"); page != "" {
+		if p, err := strconv.Atoi(page); err == nil {
+			filter.Page = p
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		if l, err := strconv.Atoi(limit); err == nil {
+			filter.Limit = l
+		}
+	}
+
+	users, total, err := h.adminService.ListUsers(filter)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Users retrieved successfully",
+		"data":    users,
+		"meta": fiber.Map{
+			"total": total,
+			"page":  filter.Page,
+			"limit": filter.Limit,
+		},
+	})
+}
+
+func (h *AdminHandler) GetUser(c *fiber.Ctx) error {
+	id, err := parseID(c, "user")
+	if err != nil {
+		return err
+	}
+
+	user, err := h.adminService.GetUser(id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "User retrieved successfully",
+		"data":    user,
+	})
+}
+
+func (h *AdminHandler) DisableUser(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "user")
+	if err != nil {
+		return err
+	}
+
+	user, err := h.adminService.DisableUser(adminID, id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "User disabled successfully",
+		"data":    user,
+	})
+}
+
+func (h *AdminHandler) EnableUser(c *fiber.Ctx) error {
+	id, err := parseID(c, "user")
+	if err != nil {
+		return err
+	}
+
+	user, err := h.adminService.EnableUser(id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "User enabled successfully",
+		"data":    user,
+	})
+}
+
+func (h *AdminHandler) ForcePasswordReset(c *fiber.Ctx) error {
+	id, err := parseID(c, "user")
+	if err != nil {
+		return err
+	}
+
+	user, err := h.adminService.ForcePasswordReset(id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Password reset forced, the user has been signed out and emailed a reset link",
+		"data":    user,
+	})
+}
+
+func (h *AdminHandler) UpdateRole(c *fiber.Ctx) error {
+	adminID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "user")
+	if err != nil {
+		return err
+	}
+
+	var req domain.UpdateRoleRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.adminService.UpdateRole(adminID, id, req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "User role updated successfully",
+		"data":    user,
+	})
+}
+
+func (h *AdminHandler) UnlockUser(c *fiber.Ctx) error {
+	id, err := parseID(c, "user")
+	if err != nil {
+		return err
+	}
+
+	user, err := h.adminService.UnlockUser(id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "User unlocked successfully",
+		"data":    user,
+	})
+}
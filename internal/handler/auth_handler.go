@@ -17,17 +17,13 @@ func NewAuthHandler(authService service.AuthService) *AuthHandler {
 
 func (h *AuthHandler) Register(c *fiber.Ctx) error {
 	var req domain.RegisterRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	user, err := h.authService.Register(req)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
@@ -38,17 +34,13 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 
 func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	var req domain.LoginRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	response, err := h.authService.Login(req)
+	response, err := h.authService.Login(req, clientInfo(c))
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -57,10 +49,179 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	})
 }
 
+func (h *AuthHandler) LoginMFA(c *fiber.Ctx) error {
+	var req domain.MFALoginRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	response, err := h.authService.LoginMFA(req, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Login successful",
+		"data":    response,
+	})
+}
+
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var req domain.RefreshRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	response, err := h.authService.Refresh(req, clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Token refreshed successfully",
+		"data":    response,
+	})
+}
+
 func (h *AuthHandler) Logout(c *fiber.Ctx) error {
-	// JWT is stateless, so we just return success
-	// In production, you might want to implement token blacklisting
+	sessionID := c.Locals("sessionID").(uint)
+
+	if err := h.authService.Logout(sessionID); err != nil {
+		return err
+	}
+
 	return c.JSON(fiber.Map{
 		"message": "Logout successful",
 	})
 }
+
+func (h *AuthHandler) GetSessions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	sessionID := c.Locals("sessionID").(uint)
+
+	sessions, err := h.authService.ListSessions(userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Sessions retrieved successfully",
+		"data":    sessions,
+	})
+}
+
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "session")
+	if err != nil {
+		return err
+	}
+
+	if err := h.authService.RevokeSession(id, userID); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Session revoked successfully",
+	})
+}
+
+func (h *AuthHandler) RevokeOtherSessions(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	sessionID := c.Locals("sessionID").(uint)
+
+	count, err := h.authService.RevokeOtherSessions(userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Other sessions revoked successfully",
+		"data": fiber.Map{
+			"revoked": count,
+		},
+	})
+}
+
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req domain.ForgotPasswordRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.authService.ForgotPassword(req); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "If the email is registered, a password reset link has been sent",
+	})
+}
+
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var req domain.ResetPasswordRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.authService.ResetPassword(req); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Password reset successfully",
+	})
+}
+
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	var req domain.VerifyEmailRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.authService.VerifyEmail(req); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Email verified successfully",
+	})
+}
+
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	var req domain.ResendVerificationRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.authService.ResendVerification(req, clientInfo(c)); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "If the email is registered and unverified, a verification link has been sent",
+	})
+}
+
+func (h *AuthHandler) UnlockAccount(c *fiber.Ctx) error {
+	var req domain.UnlockAccountRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.authService.UnlockAccount(req); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Account unlocked successfully",
+	})
+}
+
+func clientInfo(c *fiber.Ctx) domain.ClientInfo {
+	return domain.ClientInfo{
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IPAddress: c.IP(),
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/iskhakmuhamad/todo-api/internal/domain"
 	"github.com/iskhakmuhamad/todo-api/internal/service"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -21,19 +22,16 @@ func (h *CategoryHandler) Create(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 
 	var req domain.CreateCategoryRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	category, err := h.categoryService.Create(userID, req)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
+	setETag(c, category.Version)
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Category created successfully",
 		"data":    category,
@@ -45,9 +43,7 @@ func (h *CategoryHandler) GetAll(c *fiber.Ctx) error {
 
 	categories, err := h.categoryService.GetAll(userID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -59,18 +55,19 @@ func (h *CategoryHandler) GetAll(c *fiber.Ctx) error {
 func (h *CategoryHandler) GetByID(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 
-	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	id, err := parseID(c, "category")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid category ID",
-		})
+		return err
 	}
 
-	category, err := h.categoryService.GetByID(uint(id), userID)
+	category, err := h.categoryService.GetByID(id, userID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Category not found",
-		})
+		return err
+	}
+
+	setETag(c, category.Version)
+	if notModified(c, category.Version) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 
 	return c.JSON(fiber.Map{
@@ -79,30 +76,60 @@ func (h *CategoryHandler) GetByID(c *fiber.Ctx) error {
 	})
 }
 
-func (h *CategoryHandler) Update(c *fiber.Ctx) error {
+func (h *CategoryHandler) Replace(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "category")
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	var req domain.ReplaceCategoryRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	category, err := h.categoryService.Replace(id, userID, version, req)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, category.Version)
+	return c.JSON(fiber.Map{
+		"message": "Category updated successfully",
+		"data":    category,
+	})
+}
+
+func (h *CategoryHandler) Patch(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 
-	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	id, err := parseID(c, "category")
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid category ID",
-		})
+		return err
 	}
 
-	var req domain.UpdateCategoryRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	var patch domain.CategoryPatch
+	if err := parsePatch(c, &patch); err != nil {
+		return err
 	}
 
-	category, err := h.categoryService.Update(uint(id), userID, req)
+	category, err := h.categoryService.Patch(id, userID, version, patch)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
+	setETag(c, category.Version)
 	return c.JSON(fiber.Map{
 		"message": "Category updated successfully",
 		"data":    category,
@@ -112,20 +139,38 @@ func (h *CategoryHandler) Update(c *fiber.Ctx) error {
 func (h *CategoryHandler) Delete(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 
-	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	id, err := parseID(c, "category")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid category ID",
-		})
+		return err
 	}
 
-	if err := h.categoryService.Delete(uint(id), userID); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	req := domain.DeleteCategoryRequest{
+		Strategy: domain.CategoryDeleteStrategy(c.Query("strategy")),
+	}
+	if targetID := c.Query("target_id"); targetID != "" {
+		target, err := strconv.ParseUint(targetID, 10, 32)
+		if err != nil {
+			return utils.NewFieldError("target_id", "numeric", "target_id must be a category ID")
+		}
+		req.TargetID = new(uint)
+		*req.TargetID = uint(target)
+	}
+	if err := utils.Validate(req); err != nil {
+		return err
+	}
+
+	result, err := h.categoryService.Delete(id, userID, version, req)
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(fiber.Map{
 		"message": "Category deleted successfully",
+		"data":    result,
 	})
 }
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/apperror"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var errInvalidBody = apperror.BadRequest("invalid_body", "Invalid request body")
+
+// Problem is an RFC 7807 problem details body. Code is stable and meant for
+// clients to switch on; Detail is for humans and may change.
+type Problem struct {
+	Type      string             `json:"type"`
+	Title     string             `json:"title"`
+	Status    int                `json:"status"`
+	Detail    string             `json:"detail,omitempty"`
+	Instance  string             `json:"instance,omitempty"`
+	Code      string             `json:"code"`
+	RequestID string             `json:"request_id,omitempty"`
+	Errors    []utils.FieldError `json:"errors,omitempty"`
+}
+
+var kindStatus = map[apperror.Kind]int{
+	apperror.KindBadRequest:         fiber.StatusBadRequest,
+	apperror.KindUnauthorized:       fiber.StatusUnauthorized,
+	apperror.KindForbidden:          fiber.StatusForbidden,
+	apperror.KindNotFound:           fiber.StatusNotFound,
+	apperror.KindConflict:           fiber.StatusConflict,
+	apperror.KindPreconditionFailed: fiber.StatusPreconditionFailed,
+	apperror.KindValidation:         fiber.StatusUnprocessableEntity,
+	apperror.KindTooManyRequests:    fiber.StatusTooManyRequests,
+	apperror.KindServiceUnavailable: fiber.StatusServiceUnavailable,
+}
+
+// ErrorHandler is the application wide fiber error handler. It is the only
+// place where errors are turned into HTTP responses.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	problem := Problem{
+		Type:     "about:blank",
+		Instance: c.OriginalURL(),
+	}
+	if requestID, ok := c.Locals("requestid").(string); ok {
+		problem.RequestID = requestID
+	}
+
+	var (
+		appErr   *apperror.Error
+		validErr *utils.ValidationError
+		fiberErr *fiber.Error
+	)
+	switch {
+	case errors.As(err, &validErr):
+		problem.Status = fiber.StatusUnprocessableEntity
+		problem.Code = "validation_failed"
+		problem.Detail = "Validation failed"
+		problem.Errors = validErr.Fields
+	case errors.As(err, &appErr):
+		problem.Status = kindStatus[appErr.Kind]
+		problem.Code = appErr.Code
+		problem.Detail = appErr.Message
+		if appErr.RetryAfter > 0 {
+			seconds := int(appErr.RetryAfter.Round(time.Second) / time.Second)
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(seconds, 1)))
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		problem.Status = fiber.StatusNotFound
+		problem.Code = "not_found"
+		problem.Detail = "Resource not found"
+	case errors.As(err, &fiberErr):
+		problem.Status = fiberErr.Code
+		problem.Code = statusCode(fiberErr.Code)
+		problem.Detail = fiberErr.Message
+	}
+
+	if problem.Status == 0 {
+		log.Printf("request %s: %s %s: %v", problem.RequestID, c.Method(), c.Path(), err)
+		problem.Status = fiber.StatusInternalServerError
+		problem.Code = "internal_error"
+		problem.Detail = "An unexpected error occurred"
+	}
+	problem.Title = http.StatusText(problem.Status)
+
+	return c.Status(problem.Status).JSON(problem, "application/problem+json")
+}
+
+// statusCode derives a code such as "method_not_allowed" from the status.
+func statusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
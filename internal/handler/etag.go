@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/iskhakmuhamad/todo-api/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// etag formats a record version as a strong entity tag.
+func etag(version uint) string {
+	return `"` + strconv.FormatUint(uint64(version), 10) + `"`
+}
+
+func setETag(c *fiber.Ctx, version uint) {
+	c.Set(fiber.HeaderETag, etag(version))
+}
+
+// ifMatch returns the version required by the If-Match header, or 0 when the
+// header is absent or "*". A tag that is not one of ours can never match.
+func ifMatch(c *fiber.Ctx) (uint, error) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		// If-Match uses strong comparison, so weak tags never match
+		if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+			continue
+		}
+		version, err := strconv.ParseUint(tag[1:len(tag)-1], 10, 32)
+		if err == nil && version > 0 {
+			return uint(version), nil
+		}
+	}
+	return 0, service.ErrPreconditionFailed
+}
+
+// notModified reports whether If-None-Match matches the current version, in
+// which case the caller should answer 304 instead of sending the body.
+func notModified(c *fiber.Ctx, version uint) bool {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfNoneMatch))
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		// If-None-Match uses weak comparison
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == current {
+			return true
+		}
+	}
+	return false
+}
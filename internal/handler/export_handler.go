@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/iskhakmuhamad/todo-api/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ExportHandler struct {
+	exportService service.ExportService
+}
+
+func NewExportHandler(exportService service.ExportService) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+func (h *ExportHandler) Create(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	job, err := h.exportService.Request(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Export started",
+		"data":    job,
+	})
+}
+
+func (h *ExportHandler) GetAll(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	jobs, err := h.exportService.GetAll(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Exports retrieved successfully",
+		"data":    jobs,
+	})
+}
+
+func (h *ExportHandler) GetByID(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	id, err := parseID(c, "export")
+	if err != nil {
+		return err
+	}
+
+	job, err := h.exportService.GetByID(id, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Export retrieved successfully",
+		"data":    job,
+	})
+}
+
+func (h *ExportHandler) Download(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	id, err := parseID(c, "export")
+	if err != nil {
+		return err
+	}
+
+	path, err := h.exportService.ArchivePath(id, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.Download(path, fmt.Sprintf("todo-export-%d.zip", id))
+}
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type JWKSHandler struct {
+	jwtKeys *utils.KeySet
+}
+
+func NewJWKSHandler(jwtKeys *utils.KeySet) *JWKSHandler {
+	return &JWKSHandler{jwtKeys: jwtKeys}
+}
+
+// GetKeys publishes the public signing keys so other services can verify
+// access tokens without sharing a secret.
+func (h *JWKSHandler) GetKeys(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(h.jwtKeys.JWKS())
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type MFAHandler struct {
+	mfaService service.MFAService
+}
+
+func NewMFAHandler(mfaService service.MFAService) *MFAHandler {
+	return &MFAHandler{mfaService: mfaService}
+}
+
+func (h *MFAHandler) Enroll(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	response, err := h.mfaService.Enroll(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Scan the otpauth URI with an authenticator app, then confirm with a code",
+		"data":    response,
+	})
+}
+
+func (h *MFAHandler) Confirm(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req domain.MFACodeRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	codes, err := h.mfaService.Confirm(userID, req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Two-factor authentication enabled, store the recovery codes somewhere safe",
+		"data": fiber.Map{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+func (h *MFAHandler) Disable(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req domain.MFADisableRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	if err := h.mfaService.Disable(userID, req); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req domain.MFACodeRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(userID, req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Recovery codes regenerated",
+		"data": fiber.Map{
+			"recovery_codes": codes,
+		},
+	})
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"github.com/iskhakmuhamad/todo-api/internal/apperror"
+	"github.com/iskhakmuhamad/todo-api/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type OIDCHandler struct {
+	oidcService service.OIDCService
+}
+
+func NewOIDCHandler(oidcService service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{oidcService: oidcService}
+}
+
+func (h *OIDCHandler) Login(c *fiber.Ctx) error {
+	response, err := h.oidcService.Begin(c.Query("device_name"))
+	if err != nil {
+		return err
+	}
+
+	if c.QueryBool("redirect") {
+		return c.Redirect(response.AuthorizationURL, fiber.StatusFound)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Redirect the user to the authorization URL",
+		"data":    response,
+	})
+}
+
+func (h *OIDCHandler) Callback(c *fiber.Ctx) error {
+	if errCode := c.Query("error"); errCode != "" {
+		return apperror.Unauthorized("oidc_provider_error", "Identity provider returned an error: "+errCode)
+	}
+
+	response, err := h.oidcService.Callback(c.Query("code"), c.Query("state"), clientInfo(c))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Login successful",
+		"data":    response,
+	})
+}
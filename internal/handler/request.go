@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iskhakmuhamad/todo-api/internal/apperror"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const mimeMergePatch = "application/merge-patch+json"
+
+// parseBody decodes the request body into req and checks its validate tags.
+func parseBody(c *fiber.Ctx, req interface{}) error {
+	if err := c.BodyParser(req); err != nil {
+		return errInvalidBody
+	}
+	return utils.Validate(req)
+}
+
+// parseID reads the :id route parameter. name is used in the error message.
+func parseID(c *fiber.Ctx, name string) (uint, error) {
+	return parseParamID(c, "id", name)
+}
+
+// parseParamID reads an ID from the given route parameter.
+func parseParamID(c *fiber.Ctx, param, name string) (uint, error) {
+	id, err := strconv.ParseUint(c.Params(param), 10, 32)
+	if err != nil {
+		return 0, apperror.BadRequest("invalid_id", fmt.Sprintf("Invalid %s ID", name))
+	}
+	return uint(id), nil
+}
+
+// queryIDs reads a comma-separated list of IDs from the query string, e.g.
+// ?tags_any=1,2.
+func queryIDs(c *fiber.Ctx, name string) ([]uint, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	var ids []uint
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+		if err != nil || id == 0 {
+			return nil, utils.NewFieldError(name, "ids", name+" must be a comma-separated list of IDs")
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// parsePatch decodes a JSON merge patch (RFC 7396) body. Both
+// application/merge-patch+json and plain application/json are accepted.
+func parsePatch(c *fiber.Ctx, patch interface{}) error {
+	contentType := strings.ToLower(strings.TrimSpace(strings.SplitN(c.Get(fiber.HeaderContentType), ";", 2)[0]))
+	if contentType != mimeMergePatch && contentType != fiber.MIMEApplicationJSON {
+		return fiber.ErrUnsupportedMediaType
+	}
+
+	if err := json.Unmarshal(c.Body(), patch); err != nil {
+		return errInvalidBody
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TagHandler struct {
+	tagService service.TagService
+}
+
+func NewTagHandler(tagService service.TagService) *TagHandler {
+	return &TagHandler{tagService: tagService}
+}
+
+func (h *TagHandler) Create(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req domain.CreateTagRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	tag, err := h.tagService.Create(userID, req)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, tag.Version)
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Tag created successfully",
+		"data":    tag,
+	})
+}
+
+func (h *TagHandler) GetAll(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	tags, err := h.tagService.GetAll(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Tags retrieved successfully",
+		"data":    tags,
+	})
+}
+
+func (h *TagHandler) GetByID(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "tag")
+	if err != nil {
+		return err
+	}
+
+	tag, err := h.tagService.GetByID(id, userID)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, tag.Version)
+	if notModified(c, tag.Version) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Tag retrieved successfully",
+		"data":    tag,
+	})
+}
+
+func (h *TagHandler) Replace(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "tag")
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	var req domain.ReplaceTagRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	tag, err := h.tagService.Replace(id, userID, version, req)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, tag.Version)
+	return c.JSON(fiber.Map{
+		"message": "Tag updated successfully",
+		"data":    tag,
+	})
+}
+
+func (h *TagHandler) Patch(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "tag")
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	var patch domain.TagPatch
+	if err := parsePatch(c, &patch); err != nil {
+		return err
+	}
+
+	tag, err := h.tagService.Patch(id, userID, version, patch)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, tag.Version)
+	return c.JSON(fiber.Map{
+		"message": "Tag updated successfully",
+		"data":    tag,
+	})
+}
+
+func (h *TagHandler) Delete(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "tag")
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.tagService.Delete(id, userID, version); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Tag deleted successfully",
+	})
+}
+
+func (h *TagHandler) Merge(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "tag")
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	var req domain.MergeTagRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	result, err := h.tagService.Merge(id, userID, version, req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Tags merged successfully",
+		"data":    result,
+	})
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/iskhakmuhamad/todo-api/internal/domain"
 	"github.com/iskhakmuhamad/todo-api/internal/service"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -21,19 +22,16 @@ func (h *TodoHandler) Create(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 
 	var req domain.CreateTodoRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
 	todo, err := h.todoService.Create(userID, req)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
+	setETag(c, todo.Version)
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Todo created successfully",
 		"data":    todo,
@@ -48,6 +46,12 @@ func (h *TodoHandler) GetAll(c *fiber.Ctx) error {
 		Status:   domain.Status(c.Query("status")),
 		Priority: domain.Priority(c.Query("priority")),
 		Keyword:  c.Query("keyword"),
+
+		IncludeSubtasks: c.QueryBool("include_subtasks"),
+		Sort:            c.Query("sort"),
+	}
+	if filter.Sort != "" && filter.Sort != domain.SortCreated && filter.Sort != domain.SortManual {
+		return utils.NewFieldError("sort", "oneof", "sort must be one of: created, manual")
 	}
 
 	if categoryID := c.Query("category_id"); categoryID != "" {
@@ -56,6 +60,31 @@ func (h *TodoHandler) GetAll(c *fiber.Ctx) error {
 		}
 	}
 
+	if parentID := c.Query("parent_id"); parentID != "" {
+		if id, err := strconv.ParseUint(parentID, 10, 32); err == nil {
+			filter.ParentID = uint(id)
+		}
+	}
+
+	var err error
+	if filter.TagsAny, err = queryIDs(c, "tags_any"); err != nil {
+		return err
+	}
+	if filter.TagsAll, err = queryIDs(c, "tags_all"); err != nil {
+		return err
+	}
+	if filter.TagsNone, err = queryIDs(c, "tags_none"); err != nil {
+		return err
+	}
+
+	if blocked := c.Query("blocked"); blocked != "" {
+		b, err := strconv.ParseBool(blocked)
+		if err != nil {
+			return utils.NewFieldError("blocked", "boolean", "blocked must be true or false")
+		}
+		filter.Blocked = &b
+	}
+
 	if page := c.Query("page"); page != "" {
 		if p, err := strconv.Atoi(page); err == nil {
 			filter.Page = p
@@ -70,9 +99,7 @@ func (h *TodoHandler) GetAll(c *fiber.Ctx) error {
 
 	todos, total, err := h.todoService.GetAll(userID, filter)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -89,18 +116,19 @@ func (h *TodoHandler) GetAll(c *fiber.Ctx) error {
 func (h *TodoHandler) GetByID(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 
-	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	id, err := parseID(c, "todo")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid todo ID",
-		})
+		return err
 	}
 
-	todo, err := h.todoService.GetByID(uint(id), userID)
+	todo, err := h.todoService.GetByID(id, userID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Todo not found",
-		})
+		return err
+	}
+
+	setETag(c, todo.Version)
+	if notModified(c, todo.Version) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 
 	return c.JSON(fiber.Map{
@@ -109,30 +137,60 @@ func (h *TodoHandler) GetByID(c *fiber.Ctx) error {
 	})
 }
 
-func (h *TodoHandler) Update(c *fiber.Ctx) error {
+func (h *TodoHandler) Replace(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 
-	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	id, err := parseID(c, "todo")
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid todo ID",
-		})
+		return err
 	}
 
-	var req domain.UpdateTodoRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	var req domain.ReplaceTodoRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	todo, err := h.todoService.Update(uint(id), userID, req)
+	todo, err := h.todoService.Replace(id, userID, version, req)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
+	setETag(c, todo.Version)
+	return c.JSON(fiber.Map{
+		"message": "Todo updated successfully",
+		"data":    todo,
+	})
+}
+
+func (h *TodoHandler) Patch(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "todo")
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	var patch domain.TodoPatch
+	if err := parsePatch(c, &patch); err != nil {
+		return err
+	}
+
+	todo, err := h.todoService.Patch(id, userID, version, patch)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, todo.Version)
 	return c.JSON(fiber.Map{
 		"message": "Todo updated successfully",
 		"data":    todo,
@@ -142,17 +200,18 @@ func (h *TodoHandler) Update(c *fiber.Ctx) error {
 func (h *TodoHandler) Delete(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 
-	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	id, err := parseID(c, "todo")
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid todo ID",
-		})
+		return err
 	}
 
-	if err := h.todoService.Delete(uint(id), userID); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+	if err := h.todoService.Delete(id, userID, version); err != nil {
+		return err
 	}
 
 	return c.JSON(fiber.Map{
@@ -163,39 +222,252 @@ func (h *TodoHandler) Delete(c *fiber.Ctx) error {
 func (h *TodoHandler) ToggleStatus(c *fiber.Ctx) error {
 	userID := c.Locals("userID").(uint)
 
-	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	id, err := parseID(c, "todo")
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Invalid todo ID",
-		})
+		return err
 	}
 
-	todo, err := h.todoService.GetByID(uint(id), userID)
+	version, err := ifMatch(c)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Todo not found",
-		})
+		return err
+	}
+
+	todo, err := h.todoService.ToggleStatus(id, userID, version)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, todo.Version)
+	return c.JSON(fiber.Map{
+		"message": "Todo status toggled successfully",
+		"data":    todo,
+	})
+}
+
+func (h *TodoHandler) Bulk(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	var req domain.BulkTodoRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
 	}
 
-	// Toggle status
-	newStatus := domain.StatusTodo
-	if todo.Status == domain.StatusTodo {
-		newStatus = domain.StatusDone
+	response, err := h.todoService.Bulk(userID, req)
+	if err != nil {
+		return err
 	}
 
-	updateReq := domain.UpdateTodoRequest{
-		Status: newStatus,
+	return c.JSON(fiber.Map{
+		"message": "Bulk operation completed",
+		"data":    response,
+	})
+}
+
+func (h *TodoHandler) GetSubtasks(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "todo")
+	if err != nil {
+		return err
 	}
 
-	updatedTodo, err := h.todoService.Update(uint(id), userID, updateReq)
+	subtasks, err := h.todoService.GetSubtasks(id, userID)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		return err
 	}
 
 	return c.JSON(fiber.Map{
-		"message": "Todo status toggled successfully",
-		"data":    updatedTodo,
+		"message": "Subtasks retrieved successfully",
+		"data":    subtasks,
+	})
+}
+
+func (h *TodoHandler) CreateSubtask(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "todo")
+	if err != nil {
+		return err
+	}
+
+	var req domain.CreateTodoRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	todo, err := h.todoService.CreateSubtask(id, userID, req)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, todo.Version)
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Subtask created successfully",
+		"data":    todo,
+	})
+}
+
+func (h *TodoHandler) ReorderSubtasks(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "todo")
+	if err != nil {
+		return err
+	}
+
+	var req domain.ReorderSubtasksRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	subtasks, err := h.todoService.ReorderSubtasks(id, userID, req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Subtasks reordered successfully",
+		"data":    subtasks,
+	})
+}
+
+func (h *TodoHandler) Skip(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "todo")
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	todo, err := h.todoService.Skip(id, userID, version)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, todo.Version)
+	return c.JSON(fiber.Map{
+		"message": "Occurrence skipped successfully",
+		"data":    todo,
+	})
+}
+
+func (h *TodoHandler) GetDependencies(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "todo")
+	if err != nil {
+		return err
+	}
+
+	dependencies, err := h.todoService.GetDependencies(id, userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Dependencies retrieved successfully",
+		"data":    dependencies,
+	})
+}
+
+func (h *TodoHandler) AddDependency(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "todo")
+	if err != nil {
+		return err
+	}
+
+	var req domain.AddDependencyRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	dependencies, err := h.todoService.AddDependency(id, userID, req)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Dependency added successfully",
+		"data":    dependencies,
+	})
+}
+
+func (h *TodoHandler) RemoveDependency(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "todo")
+	if err != nil {
+		return err
+	}
+	blockerID, err := parseParamID(c, "blockerId", "blocker")
+	if err != nil {
+		return err
+	}
+
+	if err := h.todoService.RemoveDependency(id, blockerID, userID); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Dependency removed successfully",
+	})
+}
+
+func (h *TodoHandler) Next(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 10)
+
+	todos, total, err := h.todoService.Next(userID, page, limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Todos retrieved successfully",
+		"data":    todos,
+		"meta": fiber.Map{
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
+
+func (h *TodoHandler) Move(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "todo")
+	if err != nil {
+		return err
+	}
+
+	version, err := ifMatch(c)
+	if err != nil {
+		return err
+	}
+
+	var req domain.MoveTodoRequest
+	if err := parseBody(c, &req); err != nil {
+		return err
+	}
+
+	todo, err := h.todoService.Move(id, userID, version, req)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, todo.Version)
+	return c.JSON(fiber.Map{
+		"message": "Todo moved successfully",
+		"data":    todo,
 	})
 }
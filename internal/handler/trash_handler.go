@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"github.com/iskhakmuhamad/todo-api/internal/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TrashHandler struct {
+	trashService service.TrashService
+}
+
+func NewTrashHandler(trashService service.TrashService) *TrashHandler {
+	return &TrashHandler{trashService: trashService}
+}
+
+func (h *TrashHandler) GetTodos(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	todos, err := h.trashService.GetTodos(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Deleted todos retrieved successfully",
+		"data":    todos,
+	})
+}
+
+func (h *TrashHandler) RestoreTodo(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "todo")
+	if err != nil {
+		return err
+	}
+
+	todo, err := h.trashService.RestoreTodo(id, userID)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, todo.Version)
+	return c.JSON(fiber.Map{
+		"message": "Todo restored successfully",
+		"data":    todo,
+	})
+}
+
+func (h *TrashHandler) PurgeTodo(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "todo")
+	if err != nil {
+		return err
+	}
+
+	if err := h.trashService.PurgeTodo(id, userID); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Todo permanently deleted",
+	})
+}
+
+func (h *TrashHandler) GetCategories(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	categories, err := h.trashService.GetCategories(userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Deleted categories retrieved successfully",
+		"data":    categories,
+	})
+}
+
+func (h *TrashHandler) RestoreCategory(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "category")
+	if err != nil {
+		return err
+	}
+
+	category, err := h.trashService.RestoreCategory(id, userID)
+	if err != nil {
+		return err
+	}
+
+	setETag(c, category.Version)
+	return c.JSON(fiber.Map{
+		"message": "Category restored successfully",
+		"data":    category,
+	})
+}
+
+func (h *TrashHandler) PurgeCategory(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := parseID(c, "category")
+	if err != nil {
+		return err
+	}
+
+	if err := h.trashService.PurgeCategory(id, userID); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Category permanently deleted",
+	})
+}
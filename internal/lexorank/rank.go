@@ -0,0 +1,114 @@
+// Package lexorank generates string ranks for ordering a list by hand. Ranks
+// are compared byte by byte (COLLATE "C" in Postgres), and there is always
+// room for a new rank between two others, so moving an item only changes
+// the rank of that item.
+package lexorank
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+	base   = len(digits)
+)
+
+var (
+	ErrInvalid  = errors.New("rank must only use 0-9 and a-z and must not end in 0")
+	ErrNotOrder = errors.New("ranks are not in order")
+)
+
+// Between returns a rank that sorts after prev and before next. An empty
+// prev stands for the start of the list and an empty next for its end.
+func Between(prev, next string) (string, error) {
+	if !valid(prev) || !valid(next) {
+		return "", ErrInvalid
+	}
+	if next != "" && prev >= next {
+		return "", ErrNotOrder
+	}
+	return midpoint(prev, next), nil
+}
+
+// Spread returns n ranks in order, evenly spaced with room to insert
+// between them, for renumbering a whole list.
+func Spread(n int) []string {
+	// Leave about base free ranks between two neighbours
+	width, capacity := 1, base
+	for capacity <= (n+1)*base {
+		width++
+		capacity *= base
+	}
+
+	ranks := make([]string, n)
+	for i := range ranks {
+		value := (i + 1) * capacity / (n + 1)
+
+		rank := make([]byte, width)
+		for j := width - 1; j >= 0; j-- {
+			rank[j] = digits[value%base]
+			value /= base
+		}
+		ranks[i] = strings.TrimRight(string(rank), "0")
+	}
+	return ranks
+}
+
+// midpoint finds a rank between a and b, reading both as fractions in base
+// 36 with b == "" as 1. Neither may end in 0, which guarantees a rank in
+// between that does not end in 0 either.
+func midpoint(a, b string) string {
+	if b != "" {
+		// Keep the common prefix, a being padded with zeros
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(suffix(a, n), b[n:])
+		}
+	}
+
+	low := 0
+	if a != "" {
+		low = strings.IndexByte(digits, a[0])
+	}
+	high := base
+	if b != "" {
+		high = strings.IndexByte(digits, b[0])
+	}
+
+	if high-low > 1 {
+		return string(digits[(low+high)/2])
+	}
+	// The first digits are adjacent. If b goes on, its first digit alone
+	// is already in between, otherwise look further after a's first digit.
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return string(digits[low]) + midpoint(suffix(a, 1), "")
+}
+
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return digits[0]
+}
+
+func suffix(s string, i int) string {
+	if i < len(s) {
+		return s[i:]
+	}
+	return ""
+}
+
+func valid(rank string) bool {
+	for i := 0; i < len(rank); i++ {
+		if strings.IndexByte(digits, rank[i]) < 0 {
+			return false
+		}
+	}
+	return !strings.HasSuffix(rank, "0")
+}
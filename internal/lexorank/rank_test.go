@@ -0,0 +1,190 @@
+package lexorank
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		name    string
+		prev    string
+		next    string
+		want    string
+		wantErr error
+	}{
+		{name: "empty list", want: "i"},
+		{name: "start of list", next: "i", want: "9"},
+		{name: "end of list", prev: "i", want: "r"},
+		{name: "wide gap", prev: "a", next: "k", want: "f"},
+		{name: "adjacent digits", prev: "a", next: "b", want: "ai"},
+		{name: "next goes on", prev: "a", next: "b5", want: "b"},
+		{name: "common prefix", prev: "ab", next: "ad", want: "ac"},
+		{name: "prev is a prefix of next", prev: "a", next: "a5", want: "a2"},
+		{name: "before the smallest digit", next: "1", want: "0i"},
+		{name: "after the largest digit", prev: "z", want: "zi"},
+		{name: "prev ends in 0", prev: "a0", wantErr: ErrInvalid},
+		{name: "next has an invalid digit", next: "A", wantErr: ErrInvalid},
+		{name: "equal ranks", prev: "c", next: "c", wantErr: ErrNotOrder},
+		{name: "reversed ranks", prev: "d", next: "c", wantErr: ErrNotOrder},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Between(tt.prev, tt.next)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %q, %v, want error %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			checkBetween(t, tt.prev, got, tt.next)
+		})
+	}
+}
+
+// TestBetweenRepeated keeps inserting at the same place and checks the ranks
+// stay in order while growing by about one digit every few inserts.
+func TestBetweenRepeated(t *testing.T) {
+	const (
+		inserts = 200
+		maxLen  = inserts/5 + 2
+	)
+
+	tests := []struct {
+		name   string
+		insert func(ranks []string) (prev, next string, at int)
+	}{
+		{
+			name: "append",
+			insert: func(ranks []string) (string, string, int) {
+				return ranks[len(ranks)-1], "", len(ranks)
+			},
+		},
+		{
+			name: "prepend",
+			insert: func(ranks []string) (string, string, int) {
+				return "", ranks[0], 0
+			},
+		},
+		{
+			name: "always after the first item",
+			insert: func(ranks []string) (string, string, int) {
+				return ranks[0], ranks[1], 1
+			},
+		},
+		{
+			name: "always before the last item",
+			insert: func(ranks []string) (string, string, int) {
+				return ranks[len(ranks)-2], ranks[len(ranks)-1], len(ranks) - 1
+			},
+		},
+		{
+			name: "in the middle of the list",
+			insert: func(ranks []string) (string, string, int) {
+				at := len(ranks) / 2
+				return ranks[at-1], ranks[at], at
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranks := Spread(2)
+			for i := 0; i < inserts; i++ {
+				prev, next, at := tt.insert(ranks)
+				rank, err := Between(prev, next)
+				if err != nil {
+					t.Fatalf("insert %d between %q and %q: %v", i, prev, next, err)
+				}
+				checkBetween(t, prev, rank, next)
+				if len(rank) > maxLen {
+					t.Fatalf("insert %d: rank %q is longer than %d", i, rank, maxLen)
+				}
+
+				ranks = append(ranks, "")
+				copy(ranks[at+1:], ranks[at:])
+				ranks[at] = rank
+			}
+			checkSorted(t, ranks)
+		})
+	}
+}
+
+func TestSpread(t *testing.T) {
+	tests := []struct {
+		n      int
+		maxLen int
+	}{
+		{n: 0, maxLen: 0},
+		{n: 1, maxLen: 1},
+		{n: 2, maxLen: 2},
+		{n: 35, maxLen: 3},
+		{n: 100, maxLen: 3},
+		{n: 1000, maxLen: 3},
+		{n: 10000, maxLen: 4},
+	}
+
+	for _, tt := range tests {
+		ranks := Spread(tt.n)
+		if len(ranks) != tt.n {
+			t.Fatalf("Spread(%d) returned %d ranks", tt.n, len(ranks))
+		}
+		for _, rank := range ranks {
+			if !valid(rank) || rank == "" {
+				t.Fatalf("Spread(%d) returned invalid rank %q", tt.n, rank)
+			}
+			if len(rank) > tt.maxLen {
+				t.Fatalf("Spread(%d) returned %q, longer than %d", tt.n, rank, tt.maxLen)
+			}
+		}
+		checkSorted(t, ranks)
+
+		// There must be room before, after and between every rank
+		for i := 0; i <= len(ranks); i++ {
+			prev, next := "", ""
+			if i > 0 {
+				prev = ranks[i-1]
+			}
+			if i < len(ranks) {
+				next = ranks[i]
+			}
+			rank, err := Between(prev, next)
+			if err != nil {
+				t.Fatalf("Spread(%d): no room between %q and %q: %v", tt.n, prev, next, err)
+			}
+			if len(rank) > tt.maxLen+1 {
+				t.Fatalf("Spread(%d): rank %q between %q and %q is too long", tt.n, rank, prev, next)
+			}
+		}
+	}
+}
+
+func checkBetween(t *testing.T, prev, rank, next string) {
+	t.Helper()
+	if !valid(rank) || rank == "" {
+		t.Fatalf("invalid rank %q", rank)
+	}
+	if rank <= prev || (next != "" && rank >= next) {
+		t.Fatalf("rank %q is not between %q and %q", rank, prev, next)
+	}
+}
+
+func checkSorted(t *testing.T, ranks []string) {
+	t.Helper()
+	if !sort.SliceIsSorted(ranks, func(i, j int) bool { return ranks[i] < ranks[j] }) {
+		t.Fatalf("ranks are not in order: %v", ranks)
+	}
+	for i := 1; i < len(ranks); i++ {
+		if ranks[i] == ranks[i-1] {
+			t.Fatalf("duplicate rank %q", ranks[i])
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package loginguard
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Attempt is the failure counter kept for a single key.
+type Attempt struct {
+	Failures      int
+	LastFailureAt time.Time
+}
+
+// Store persists failure counters. Counters older than the store's window
+// start over at one on the next failure.
+type Store interface {
+	// Reserve counts an attempt for the key unless it has to wait
+	// delay(failures) after the last one, in which case the counter is left
+	// alone and the remaining wait is returned. Checking and counting happen
+	// atomically, so concurrent attempts cannot all pass the same check.
+	Reserve(key string, now time.Time, delay func(failures int) time.Duration) (Attempt, time.Duration, error)
+	// Release takes back one reserved attempt that did not fail.
+	Release(key string) error
+	Reset(key string) error
+}
+
+type Config struct {
+	MaxAttempts   int
+	IPMaxAttempts int
+	Lockout       time.Duration
+	BackoffBase   time.Duration
+}
+
+// LockedError is returned while a key is backing off or locked out.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// Guard throttles password guessing per account and per client IP. From the
+// second failure on, each attempt has to wait BackoffBase * 2^(n-2); after
+// MaxAttempts failures the key is locked for the full Lockout duration.
+type Guard struct {
+	store Store
+	cfg   Config
+}
+
+func New(store Store, cfg Config) *Guard {
+	return &Guard{store: store, cfg: cfg}
+}
+
+// Begin reserves an attempt for the account and the IP before the
+// credentials are checked, and returns the account's failure count
+// including this attempt. It returns a *LockedError when either has to wait
+// before trying again. The attempt counts as a failure until it is given
+// back with Release or Succeed, so parallel guesses cannot all get past the
+// limits. Unknown accounts are tracked the same way as real ones so the
+// response does not reveal whether an account exists.
+func (g *Guard) Begin(account, ip string) (int, error) {
+	now := time.Now()
+
+	attempt, wait, err := g.store.Reserve(accountKey(account), now, g.delay(g.cfg.MaxAttempts))
+	if err != nil {
+		return 0, err
+	}
+	if wait > 0 {
+		return 0, &LockedError{RetryAfter: wait}
+	}
+
+	if ip != "" {
+		_, wait, err := g.store.Reserve(ipKey(ip), now, g.delay(g.cfg.IPMaxAttempts))
+		if err == nil && wait > 0 {
+			err = &LockedError{RetryAfter: wait}
+		}
+		if err != nil {
+			if releaseErr := g.store.Release(accountKey(account)); releaseErr != nil {
+				return 0, releaseErr
+			}
+			return 0, err
+		}
+	}
+
+	return attempt.Failures, nil
+}
+
+// LocksOut reports whether failing the attempt Begin counted as failures
+// locks the account.
+func (g *Guard) LocksOut(failures int) bool {
+	return failures == g.cfg.MaxAttempts
+}
+
+// Release gives back an attempt that did not fail, e.g. a correct password
+// that still needs a second factor.
+func (g *Guard) Release(account, ip string) error {
+	if err := g.store.Release(accountKey(account)); err != nil {
+		return err
+	}
+	if ip == "" {
+		return nil
+	}
+	return g.store.Release(ipKey(ip))
+}
+
+// Succeed clears the account counter after a successful login. The IP
+// counter only gets the attempt back, so an attacker cannot reset it with
+// their own account.
+func (g *Guard) Succeed(account, ip string) error {
+	if err := g.store.Reset(accountKey(account)); err != nil {
+		return err
+	}
+	if ip == "" {
+		return nil
+	}
+	return g.store.Release(ipKey(ip))
+}
+
+// Unlock lifts an account lockout, used by admins and the email unlock link.
+func (g *Guard) Unlock(account string) error {
+	return g.store.Reset(accountKey(account))
+}
+
+// delay returns how long a key with the given number of failures has to
+// wait after the last one.
+func (g *Guard) delay(maxAttempts int) func(failures int) time.Duration {
+	return func(failures int) time.Duration {
+		switch {
+		case failures >= maxAttempts:
+			return g.cfg.Lockout
+		case failures >= 2:
+			return min(g.cfg.BackoffBase<<(failures-2), g.cfg.Lockout)
+		}
+		return 0
+	}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
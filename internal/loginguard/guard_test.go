@@ -0,0 +1,152 @@
+package loginguard
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBeginConcurrent(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         Config
+		account     func(i int) string
+		ip          func(i int) string
+		wantAllowed int
+	}{
+		{
+			name:        "one account backs off after two failures",
+			cfg:         Config{MaxAttempts: 5, IPMaxAttempts: 100, Lockout: time.Hour, BackoffBase: time.Minute},
+			account:     func(int) string { return "victim@example.com" },
+			ip:          func(i int) string { return "10.0.0." + string(rune('0'+i%10)) },
+			wantAllowed: 2,
+		},
+		{
+			name:        "one IP backs off across accounts",
+			cfg:         Config{MaxAttempts: 5, IPMaxAttempts: 100, Lockout: time.Hour, BackoffBase: time.Minute},
+			account:     func(i int) string { return string(rune('a'+i%26)) + "@example.com" },
+			ip:          func(int) string { return "10.0.0.1" },
+			wantAllowed: 2,
+		},
+		{
+			name:        "lockout without backoff",
+			cfg:         Config{MaxAttempts: 3, IPMaxAttempts: 100, Lockout: time.Hour},
+			account:     func(int) string { return "victim@example.com" },
+			ip:          func(int) string { return "" },
+			wantAllowed: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := New(NewMemoryStore(time.Hour), tt.cfg)
+
+			const attempts = 20
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			allowed := 0
+			for i := 0; i < attempts; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					_, err := guard.Begin(tt.account(i), tt.ip(i))
+					var locked *LockedError
+					switch {
+					case err == nil:
+						mu.Lock()
+						allowed++
+						mu.Unlock()
+					case !errors.As(err, &locked):
+						t.Errorf("unexpected error: %v", err)
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			if allowed != tt.wantAllowed {
+				t.Fatalf("%d of %d parallel attempts got through, want %d", allowed, attempts, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestBeginRelease(t *testing.T) {
+	guard := New(NewMemoryStore(time.Hour), Config{MaxAttempts: 3, IPMaxAttempts: 3, Lockout: time.Hour})
+
+	// Attempts given back never add up to a lockout
+	for i := 0; i < 10; i++ {
+		if _, err := guard.Begin("user@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("attempt %d: %v", i, err)
+		}
+		if err := guard.Release("user@example.com", "10.0.0.1"); err != nil {
+			t.Fatalf("release %d: %v", i, err)
+		}
+	}
+
+	// Failures do, and the one reaching MaxAttempts locks the account
+	for i := 1; i <= 3; i++ {
+		failures, err := guard.Begin("user@example.com", "10.0.0.2")
+		if err != nil {
+			t.Fatalf("failure %d: %v", i, err)
+		}
+		if failures != i || guard.LocksOut(failures) != (i == 3) {
+			t.Fatalf("failure %d: got %d failures, locks out %v", i, failures, guard.LocksOut(failures))
+		}
+	}
+	if _, err := guard.Begin("User@Example.com ", "10.0.0.3"); err == nil {
+		t.Fatal("locked account got another attempt")
+	}
+
+	if err := guard.Unlock("user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := guard.Begin("user@example.com", "10.0.0.3"); err != nil {
+		t.Fatalf("unlocked account: %v", err)
+	}
+}
+
+func TestBeginIPLockReleasesAccount(t *testing.T) {
+	guard := New(NewMemoryStore(time.Hour), Config{MaxAttempts: 3, IPMaxAttempts: 1, Lockout: time.Hour})
+
+	if _, err := guard.Begin("a@example.com", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	// The IP is locked now, which must not count against the next account
+	for i := 0; i < 5; i++ {
+		if _, err := guard.Begin("b@example.com", "10.0.0.1"); err == nil {
+			t.Fatal("locked IP got another attempt")
+		}
+	}
+	failures, err := guard.Begin("b@example.com", "10.0.0.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failures != 1 {
+		t.Fatalf("got %d failures for the account, want 1", failures)
+	}
+}
+
+func TestThrottleAllow(t *testing.T) {
+	throttle := NewThrottle(NewMemoryStore(time.Hour), "resend:", time.Hour)
+
+	if err := throttle.Allow("a@example.com", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		account string
+		ip      string
+		allowed bool
+	}{
+		{account: "a@example.com", ip: "10.0.0.2"},
+		{account: "b@example.com", ip: "10.0.0.1"},
+		{account: "b@example.com", ip: "10.0.0.3", allowed: true},
+		{account: "c@example.com", ip: "", allowed: true},
+	}
+	for _, tt := range tests {
+		err := throttle.Allow(tt.account, tt.ip)
+		if (err == nil) != tt.allowed {
+			t.Fatalf("Allow(%q, %q) = %v, want allowed %v", tt.account, tt.ip, err, tt.allowed)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package loginguard
+
+import (
+	"sync"
+	"time"
+)
+
+// memoryStore keeps counters in process memory. It is only correct when a
+// single instance of the API is running.
+type memoryStore struct {
+	window   time.Duration
+	mu       sync.Mutex
+	attempts map[string]Attempt
+	writes   int
+}
+
+func NewMemoryStore(window time.Duration) Store {
+	return &memoryStore{
+		window:   window,
+		attempts: make(map[string]Attempt),
+	}
+}
+
+func (s *memoryStore) Reserve(key string, now time.Time, delay func(failures int) time.Duration) (Attempt, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt := s.attempts[key]
+	if now.Sub(attempt.LastFailureAt) > s.window {
+		attempt = Attempt{}
+	}
+	if wait := attempt.LastFailureAt.Add(delay(attempt.Failures)).Sub(now); attempt.Failures > 0 && wait > 0 {
+		return attempt, wait, nil
+	}
+
+	attempt.Failures++
+	attempt.LastFailureAt = now
+	s.attempts[key] = attempt
+
+	// Drop stale counters every so often so the map does not grow forever
+	s.writes++
+	if s.writes%1000 == 0 {
+		for k, a := range s.attempts {
+			if now.Sub(a.LastFailureAt) > s.window {
+				delete(s.attempts, k)
+			}
+		}
+	}
+
+	return attempt, 0, nil
+}
+
+func (s *memoryStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if attempt, ok := s.attempts[key]; ok && attempt.Failures > 0 {
+		attempt.Failures--
+		s.attempts[key] = attempt
+	}
+	return nil
+}
+
+func (s *memoryStore) Reset(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
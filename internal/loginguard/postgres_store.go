@@ -0,0 +1,75 @@
+package loginguard
+
+import (
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// postgresStore shares counters between API replicas through the
+// login_attempts table.
+type postgresStore struct {
+	db     *gorm.DB
+	window time.Duration
+}
+
+func NewPostgresStore(db *gorm.DB, window time.Duration) Store {
+	return &postgresStore{db: db, window: window}
+}
+
+// Reserve locks the key's row while it decides, so concurrent attempts on
+// different replicas are checked and counted one at a time.
+func (s *postgresStore) Reserve(key string, now time.Time, delay func(failures int) time.Duration) (Attempt, time.Duration, error) {
+	var attempt Attempt
+	var wait time.Duration
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Make sure there is a row to lock
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&domain.LoginAttempt{AttemptKey: key, LastFailureAt: now}).Error
+		if err != nil {
+			return err
+		}
+
+		var row domain.LoginAttempt
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("attempt_key = ?", key).
+			First(&row).Error
+		if err != nil {
+			return err
+		}
+
+		attempt = Attempt{Failures: row.Failures, LastFailureAt: row.LastFailureAt}
+		if now.Sub(attempt.LastFailureAt) > s.window {
+			attempt = Attempt{}
+		}
+		if wait = attempt.LastFailureAt.Add(delay(attempt.Failures)).Sub(now); attempt.Failures > 0 && wait > 0 {
+			return nil
+		}
+
+		wait = 0
+		attempt = Attempt{Failures: attempt.Failures + 1, LastFailureAt: now}
+		return tx.Model(&domain.LoginAttempt{}).
+			Where("attempt_key = ?", key).
+			Updates(map[string]interface{}{
+				"failures":        attempt.Failures,
+				"last_failure_at": attempt.LastFailureAt,
+			}).Error
+	})
+	if err != nil {
+		return Attempt{}, 0, err
+	}
+	return attempt, wait, nil
+}
+
+func (s *postgresStore) Release(key string) error {
+	return s.db.Model(&domain.LoginAttempt{}).
+		Where("attempt_key = ? AND failures > 0", key).
+		Update("failures", gorm.Expr("failures - 1")).Error
+}
+
+func (s *postgresStore) Reset(key string) error {
+	return s.db.Where("attempt_key = ?", key).Delete(&domain.LoginAttempt{}).Error
+}
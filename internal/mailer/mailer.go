@@ -0,0 +1,25 @@
+package mailer
+
+import (
+	"github.com/iskhakmuhamad/todo-api/internal/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers outgoing email. Services only depend on this interface so
+// the transport can be swapped through configuration.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New returns the mailer selected by MAIL_DRIVER ("smtp" or "log").
+func New(cfg *config.Config) Mailer {
+	if cfg.MailDriver == "smtp" {
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	}
+	return NewLogMailer(cfg.MailLogFile)
+}
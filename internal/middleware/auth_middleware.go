@@ -1,42 +1,205 @@
 package middleware
 
 import (
+	"errors"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/iskhakmuhamad/todo-api/internal/apperror"
+	"github.com/iskhakmuhamad/todo-api/internal/config"
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/repository"
 	"github.com/iskhakmuhamad/todo-api/pkg/utils"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+var (
+	errMissingToken      = apperror.Unauthorized("missing_token", "Authorization header required")
+	errInvalidToken      = apperror.Unauthorized("invalid_token", "Invalid token")
+	errSessionRevoked    = apperror.Unauthorized("session_revoked", "Session expired or revoked")
+	errAccountDisabled   = apperror.Forbidden("account_disabled", "Account is disabled")
+	errPasswordReset     = apperror.Forbidden("password_reset_required", "Password reset required, check your email for a reset link")
+	errInsufficientScope = apperror.Forbidden("insufficient_scope", "Access token is missing the required scope")
+	errInsufficientRole  = apperror.Forbidden("insufficient_role", "Insufficient permissions")
+	errEmailNotVerified  = apperror.Forbidden("email_not_verified", "Email address is not verified")
 )
 
 type AuthMiddleware struct {
-	jwtSecret string
+	cfg             *config.Config
+	jwtKeys         *utils.KeySet
+	sessionRepo     repository.SessionRepository
+	accessTokenRepo repository.AccessTokenRepository
 }
 
-func NewAuthMiddleware(jwtSecret string) *AuthMiddleware {
+func NewAuthMiddleware(
+	cfg *config.Config,
+	jwtKeys *utils.KeySet,
+	sessionRepo repository.SessionRepository,
+	accessTokenRepo repository.AccessTokenRepository,
+) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtSecret: jwtSecret,
+		cfg:             cfg,
+		jwtKeys:         jwtKeys,
+		sessionRepo:     sessionRepo,
+		accessTokenRepo: accessTokenRepo,
 	}
 }
 
+// ValidateJWT only accepts session JWTs. It guards account management routes
+// that personal access tokens must not reach.
 func (m *AuthMiddleware) ValidateJWT(c *fiber.Ctx) error {
-	authHeader := c.Get("Authorization")
-	if authHeader == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Authorization header required",
-		})
+	tokenString, ok := bearerToken(c)
+	if !ok {
+		return errMissingToken
+	}
+
+	return m.authenticateJWT(c, tokenString)
+}
+
+// Authenticate accepts either a session JWT or a personal access token.
+// Scopes of access tokens are enforced separately by RequireScope.
+func (m *AuthMiddleware) Authenticate(c *fiber.Ctx) error {
+	tokenString, ok := bearerToken(c)
+	if !ok {
+		return errMissingToken
+	}
+
+	if strings.HasPrefix(tokenString, domain.AccessTokenPrefix) {
+		return m.authenticateAccessToken(c, tokenString)
 	}
+	return m.authenticateJWT(c, tokenString)
+}
+
+// RequireScope checks that an access token holds "<resource>:read" for safe
+// methods and "<resource>:write" for everything else. A write scope also
+// grants read access. Session JWTs are not scoped.
+func (m *AuthMiddleware) RequireScope(resource string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scopes, scoped := c.Locals("scopes").([]string)
+		if !scoped {
+			return c.Next()
+		}
+
+		allowed := slices.Contains(scopes, resource+":write")
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			allowed = allowed || slices.Contains(scopes, resource+":read")
+		}
+
+		if !allowed {
+			return errInsufficientScope
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireRole only lets through users holding one of the given roles. The
+// role is read from the database on every request, so demotions apply
+// immediately rather than when the access token expires.
+func (m *AuthMiddleware) RequireRole(roles ...domain.Role) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals("role").(domain.Role)
+		if !slices.Contains(roles, role) {
+			return errInsufficientRole
+		}
+		return c.Next()
+	}
+}
+
+// RequireVerifiedEmail makes the API read-only for unverified accounts when
+// REQUIRE_EMAIL_VERIFICATION is "write". It must run after ValidateJWT.
+func (m *AuthMiddleware) RequireVerifiedEmail(c *fiber.Ctx) error {
+	if m.cfg.RequireEmailVerification != "write" {
+		return c.Next()
+	}
+
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return c.Next()
+	}
+
+	if verified, _ := c.Locals("emailVerified").(bool); !verified {
+		return errEmailNotVerified
+	}
+
+	return c.Next()
+}
 
-	tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-	claims, err := utils.ValidateJWT(tokenString, m.jwtSecret)
+func (m *AuthMiddleware) authenticateJWT(c *fiber.Ctx, tokenString string) error {
+	claims, err := utils.ValidateJWT(tokenString, m.jwtKeys)
 	if err != nil {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid token",
-		})
+		return errInvalidToken
+	}
+
+	// Reject tokens whose session was revoked by logout or refresh token reuse
+	session, err := m.sessionRepo.GetByID(claims.SessionID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil || session.UserID != claims.UserID || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return errSessionRevoked
+	}
+
+	if session.User.DisabledAt != nil {
+		return errAccountDisabled
+	}
+
+	// Keep last-seen reasonably fresh without writing on every request
+	if time.Since(session.LastSeenAt) > time.Minute {
+		_ = m.sessionRepo.Touch(session.ID, c.IP())
 	}
 
 	// Store user info in context
 	c.Locals("userID", claims.UserID)
 	c.Locals("email", claims.Email)
+	c.Locals("sessionID", claims.SessionID)
+	c.Locals("role", session.User.Role)
+	c.Locals("emailVerified", session.User.EmailVerifiedAt != nil)
 
 	return c.Next()
 }
+
+func (m *AuthMiddleware) authenticateAccessToken(c *fiber.Ctx, tokenString string) error {
+	token, err := m.accessTokenRepo.GetByHash(utils.HashToken(tokenString))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil || token.RevokedAt != nil || (token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt)) {
+		return errInvalidToken
+	}
+
+	if token.User.DisabledAt != nil {
+		return errAccountDisabled
+	}
+	// Tokens are revoked when a reset is forced, this covers any created
+	// while it is pending
+	if token.User.MustResetPassword {
+		return errPasswordReset
+	}
+
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > time.Minute {
+		_ = m.accessTokenRepo.TouchLastUsed(token.ID)
+	}
+
+	// Store user info in context
+	c.Locals("userID", token.UserID)
+	c.Locals("email", token.User.Email)
+	c.Locals("accessTokenID", token.ID)
+	c.Locals("role", token.User.Role)
+	c.Locals("scopes", token.Scopes)
+	c.Locals("emailVerified", token.User.EmailVerifiedAt != nil)
+
+	return c.Next()
+}
+
+func bearerToken(c *fiber.Ctx) (string, bool) {
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		return "", false
+	}
+	return strings.Replace(authHeader, "Bearer ", "", 1), true
+}
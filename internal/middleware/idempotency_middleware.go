@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/apperror"
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/repository"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	headerIdempotencyKey     = "Idempotency-Key"
+	headerIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+
+	// An in-flight key older than this is assumed to belong to a request that
+	// died without releasing it
+	idempotencyLockTimeout = time.Minute
+
+	idempotencySweepInterval = time.Hour
+)
+
+var (
+	errInvalidIdempotencyKey     = apperror.BadRequest("invalid_idempotency_key", "Idempotency-Key must be at most 255 characters")
+	errIdempotencyKeyReused      = apperror.Conflict("idempotency_key_reused", "Idempotency-Key was already used for a different request")
+	errIdempotencyKeyInProgress  = apperror.Conflict("idempotency_key_in_progress", "A request with this Idempotency-Key is still being processed")
+	errIdempotencyKeyUnsupported = apperror.BadRequest("idempotency_key_not_supported", "This endpoint does not support Idempotency-Key")
+)
+
+// IdempotencyMiddleware replays the stored response when a POST or PATCH is
+// retried with the same Idempotency-Key. Keys are scoped to the user, so it
+// must run after authentication and scope checks. Responses are stored as
+// is, so it must not guard routes that return secrets.
+type IdempotencyMiddleware struct {
+	repo   repository.IdempotencyRepository
+	window time.Duration
+}
+
+func NewIdempotencyMiddleware(repo repository.IdempotencyRepository, window time.Duration) *IdempotencyMiddleware {
+	return &IdempotencyMiddleware{repo: repo, window: window}
+}
+
+// Start removes expired keys in the background.
+func (m *IdempotencyMiddleware) Start() {
+	go func() {
+		ticker := time.NewTicker(idempotencySweepInterval)
+		defer ticker.Stop()
+
+		for ; ; <-ticker.C {
+			if _, err := m.repo.DeleteExpired(time.Now()); err != nil {
+				log.Printf("idempotency: failed to delete expired keys: %v", err)
+			}
+		}
+	}()
+}
+
+func (m *IdempotencyMiddleware) Handle(c *fiber.Ctx) error {
+	if !guarded(c) {
+		return c.Next()
+	}
+
+	key := c.Get(headerIdempotencyKey)
+	userID, ok := c.Locals("userID").(uint)
+	if key == "" || !ok {
+		return c.Next()
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return errInvalidIdempotencyKey
+	}
+
+	now := time.Now()
+	record := &domain.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: requestHash(c),
+		ExpiresAt:   now.Add(m.window),
+		CreatedAt:   now,
+	}
+
+	reserved, err := m.repo.Reserve(record, now.Add(-idempotencyLockTimeout))
+	if err != nil {
+		return err
+	}
+	if !reserved {
+		return m.replay(c, record)
+	}
+
+	if err := c.Next(); err != nil {
+		// Failed requests are not stored, the client may retry with the
+		// same key
+		m.release(record)
+		return err
+	}
+
+	status := c.Response().StatusCode()
+	if status >= fiber.StatusInternalServerError {
+		m.release(record)
+		return nil
+	}
+
+	record.StatusCode = status
+	record.ContentType = string(c.Response().Header.ContentType())
+	record.ETag = string(c.Response().Header.Peek(fiber.HeaderETag))
+	record.Body = append([]byte(nil), c.Response().Body()...)
+	if err := m.repo.Complete(record); err != nil {
+		log.Printf("idempotency: failed to store response for key %q: %v", key, err)
+		m.release(record)
+	}
+	return nil
+}
+
+// Refuse rejects POST and PATCH requests that send an Idempotency-Key to
+// routes Handle does not guard, so a client retrying one does not assume the
+// key protects it from running twice.
+func (m *IdempotencyMiddleware) Refuse(c *fiber.Ctx) error {
+	if guarded(c) && c.Get(headerIdempotencyKey) != "" {
+		return errIdempotencyKeyUnsupported
+	}
+	return c.Next()
+}
+
+// guarded reports whether the request has a method idempotency keys apply to.
+func guarded(c *fiber.Ctx) bool {
+	return c.Method() == fiber.MethodPost || c.Method() == fiber.MethodPatch
+}
+
+// replay answers a retry with the response stored for the key.
+func (m *IdempotencyMiddleware) replay(c *fiber.Ctx, record *domain.IdempotencyKey) error {
+	stored, err := m.repo.Get(record.UserID, record.Key)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Released between our insert and this lookup
+			return errIdempotencyKeyInProgress
+		}
+		return err
+	}
+
+	if stored.RequestHash != record.RequestHash {
+		return errIdempotencyKeyReused
+	}
+	if stored.StatusCode == 0 {
+		return errIdempotencyKeyInProgress
+	}
+
+	if stored.ContentType != "" {
+		c.Set(fiber.HeaderContentType, stored.ContentType)
+	}
+	if stored.ETag != "" {
+		c.Set(fiber.HeaderETag, stored.ETag)
+	}
+	c.Set(headerIdempotentReplayed, "true")
+	return c.Status(stored.StatusCode).Send(stored.Body)
+}
+
+func (m *IdempotencyMiddleware) release(record *domain.IdempotencyKey) {
+	if err := m.repo.Release(record); err != nil {
+		log.Printf("idempotency: failed to release key %q: %v", record.Key, err)
+	}
+}
+
+// requestHash fingerprints the request so a key cannot be reused for a
+// different payload or endpoint.
+func requestHash(c *fiber.Ctx) string {
+	h := sha256.New()
+	h.Write([]byte(c.Method()))
+	h.Write([]byte{0})
+	h.Write([]byte(c.OriginalURL()))
+	h.Write([]byte{0})
+	h.Write(c.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
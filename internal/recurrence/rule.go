@@ -0,0 +1,329 @@
+// Package recurrence implements the subset of RFC 5545 recurrence rules used
+// for repeating todos: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL,
+// BYDAY, UNTIL and COUNT. Occurrences are computed one at a time from the
+// previous one, which keeps its time of day and location.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxSteps bounds the search for the next occurrence, so a rule that can
+// never match again (e.g. the 31st every other month from August) ends.
+const maxSteps = 1000
+
+const (
+	untilDateTime = "20060102T150405Z"
+	untilDate     = "20060102"
+)
+
+var dayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// WeekdayNum is a BYDAY entry. N picks the nth (or, when negative, nth last)
+// such weekday of the month and is 0 for every one of them.
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+func (w WeekdayNum) String() string {
+	if w.N == 0 {
+		return dayCodes[w.Day]
+	}
+	return strconv.Itoa(w.N) + dayCodes[w.Day]
+}
+
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []WeekdayNum
+	Until    *time.Time
+	Count    int
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=10".
+// A leading "RRULE:" is accepted.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "RRULE:")
+	if s == "" {
+		return nil, errors.New("rule is empty")
+	}
+
+	rule := &Rule{Interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("%s is given more than once", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			rule.Freq = Frequency(value)
+			switch rule.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, errors.New("INTERVAL must be a positive number")
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, errors.New("COUNT must be a positive number")
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = &until
+		case "BYDAY":
+			days, err := parseByDay(value)
+			if err != nil {
+				return nil, err
+			}
+			rule.ByDay = days
+		default:
+			return nil, fmt.Errorf("unsupported rule part %s", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("FREQ is required")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, errors.New("COUNT and UNTIL cannot be used together")
+	}
+	if len(rule.ByDay) > 0 && rule.Freq == Yearly {
+		return nil, errors.New("BYDAY is not supported with FREQ=YEARLY")
+	}
+	if rule.Freq != Monthly {
+		for _, day := range rule.ByDay {
+			if day.N != 0 {
+				return nil, errors.New("numbered BYDAY entries need FREQ=MONTHLY")
+			}
+		}
+	}
+	return rule, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	if until, err := time.Parse(untilDateTime, value); err == nil {
+		return until, nil
+	}
+	if until, err := time.Parse(untilDate, value); err == nil {
+		// A date includes the whole day
+		return until.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, errors.New("UNTIL must look like 20251231 or 20251231T235959Z")
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, entry := range strings.Split(value, ",") {
+		if len(entry) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY entry %q", entry)
+		}
+
+		code := entry[len(entry)-2:]
+		day := -1
+		for i, c := range dayCodes {
+			if c == code {
+				day = i
+			}
+		}
+		if day < 0 {
+			return nil, fmt.Errorf("invalid BYDAY entry %q", entry)
+		}
+
+		n := 0
+		if prefix := entry[:len(entry)-2]; prefix != "" {
+			var err error
+			n, err = strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("invalid BYDAY entry %q", entry)
+			}
+		}
+		days = append(days, WeekdayNum{N: n, Day: time.Weekday(day)})
+	}
+	return days, nil
+}
+
+// String formats the rule in canonical form, so equal rules compare equal.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = day.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilDateTime))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the occurrence after current, which is occurrence number
+// occurrence (counting from 1) of the series. ok is false once the series
+// has ended.
+func (r *Rule) Next(current time.Time, occurrence int) (next time.Time, ok bool) {
+	if r.Count > 0 && occurrence >= r.Count {
+		return time.Time{}, false
+	}
+
+	switch r.Freq {
+	case Daily:
+		next, ok = r.nextDaily(current)
+	case Weekly:
+		next, ok = r.nextWeekly(current)
+	case Monthly:
+		next, ok = r.nextMonthly(current)
+	case Yearly:
+		next, ok = r.nextYearly(current)
+	}
+	if !ok || (r.Until != nil && next.After(*r.Until)) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+func (r *Rule) nextDaily(t time.Time) (time.Time, bool) {
+	for step := 1; step <= maxSteps; step++ {
+		next := t.AddDate(0, 0, step*r.Interval)
+		if r.matchesDay(next) {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (r *Rule) nextWeekly(t time.Time) (time.Time, bool) {
+	if len(r.ByDay) == 0 {
+		return t.AddDate(0, 0, 7*r.Interval), true
+	}
+
+	// Weeks start on Monday, as with the RFC's default WKST
+	offsets := make([]int, 0, len(r.ByDay))
+	for _, day := range r.ByDay {
+		offsets = append(offsets, mondayOffset(day.Day))
+	}
+	sort.Ints(offsets)
+
+	weekStart := t.AddDate(0, 0, -mondayOffset(t.Weekday()))
+	for _, offset := range offsets {
+		if next := weekStart.AddDate(0, 0, offset); next.After(t) {
+			return next, true
+		}
+	}
+	return weekStart.AddDate(0, 0, 7*r.Interval+offsets[0]), true
+}
+
+func (r *Rule) nextMonthly(t time.Time) (time.Time, bool) {
+	for step := 0; step <= maxSteps; step++ {
+		if len(r.ByDay) == 0 {
+			if step == 0 {
+				continue
+			}
+			// Months without the day are skipped, not clamped
+			next := addMonths(t, step*r.Interval)
+			if next.Day() == t.Day() {
+				return next, true
+			}
+			continue
+		}
+
+		monthStart := addMonths(time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location()), step*r.Interval)
+		for _, candidate := range r.daysInMonth(monthStart) {
+			if candidate.After(t) {
+				return candidate, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func (r *Rule) nextYearly(t time.Time) (time.Time, bool) {
+	for step := 1; step <= maxSteps; step++ {
+		// February 29th only recurs in leap years
+		next := t.AddDate(step*r.Interval, 0, 0)
+		if next.Month() == t.Month() && next.Day() == t.Day() {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// daysInMonth lists, in order, the days of the month starting at monthStart
+// that match BYDAY.
+func (r *Rule) daysInMonth(monthStart time.Time) []time.Time {
+	length := monthStart.AddDate(0, 1, -1).Day()
+
+	var days []time.Time
+	for d := 0; d < length; d++ {
+		day := monthStart.AddDate(0, 0, d)
+		nth := d/7 + 1
+		nthLast := -((length-d-1)/7 + 1)
+		for _, by := range r.ByDay {
+			if by.Day == day.Weekday() && (by.N == 0 || by.N == nth || by.N == nthLast) {
+				days = append(days, day)
+				break
+			}
+		}
+	}
+	return days
+}
+
+func (r *Rule) matchesDay(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, day := range r.ByDay {
+		if day.Day == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// addMonths moves t by n months. An overflowing day rolls into the following
+// month (January 31st plus one month is March 3rd), which callers detect by
+// comparing the day.
+func addMonths(t time.Time, n int) time.Time {
+	return time.Date(t.Year(), t.Month()+time.Month(n), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+func mondayOffset(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
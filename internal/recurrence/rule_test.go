@@ -0,0 +1,230 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 9, 30, 0, 0, time.UTC)
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		want  []time.Time
+	}{
+		{
+			name:  "monthly on the 31st skips shorter months",
+			rule:  "FREQ=MONTHLY",
+			start: date(2025, time.January, 31),
+			want: []time.Time{
+				date(2025, time.March, 31),
+				date(2025, time.May, 31),
+				date(2025, time.July, 31),
+				date(2025, time.August, 31),
+				date(2025, time.October, 31),
+			},
+		},
+		{
+			name:  "monthly on the 30th skips February only",
+			rule:  "FREQ=MONTHLY",
+			start: date(2025, time.January, 30),
+			want: []time.Time{
+				date(2025, time.March, 30),
+				date(2025, time.April, 30),
+			},
+		},
+		{
+			name:  "every other month on the 31st skips a run of short months",
+			rule:  "FREQ=MONTHLY;INTERVAL=2",
+			start: date(2025, time.August, 31),
+			want: []time.Time{
+				date(2025, time.October, 31),
+				date(2025, time.December, 31),
+				date(2026, time.August, 31),
+			},
+		},
+		{
+			name:  "yearly on February 29th waits for leap years",
+			rule:  "FREQ=YEARLY",
+			start: date(2024, time.February, 29),
+			want: []time.Time{
+				date(2028, time.February, 29),
+				date(2032, time.February, 29),
+			},
+		},
+		{
+			name:  "second Tuesday of the month",
+			rule:  "FREQ=MONTHLY;BYDAY=2TU",
+			start: date(2025, time.January, 14),
+			want: []time.Time{
+				date(2025, time.February, 11),
+				date(2025, time.March, 11),
+				date(2025, time.April, 8),
+			},
+		},
+		{
+			name:  "last Friday of the month",
+			rule:  "FREQ=MONTHLY;BYDAY=-1FR",
+			start: date(2025, time.January, 31),
+			want: []time.Time{
+				date(2025, time.February, 28),
+				date(2025, time.March, 28),
+				date(2025, time.April, 25),
+			},
+		},
+		{
+			name:  "fifth Monday only in months that have one",
+			rule:  "FREQ=MONTHLY;BYDAY=5MO",
+			start: date(2025, time.March, 31),
+			want: []time.Time{
+				date(2025, time.June, 30),
+				date(2025, time.September, 29),
+				date(2025, time.December, 29),
+			},
+		},
+		{
+			name:  "every other week on Monday and Friday",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR",
+			start: date(2025, time.January, 6),
+			want: []time.Time{
+				date(2025, time.January, 10),
+				date(2025, time.January, 20),
+				date(2025, time.January, 24),
+				date(2025, time.February, 3),
+			},
+		},
+		{
+			name:  "every third day",
+			rule:  "FREQ=DAILY;INTERVAL=3",
+			start: date(2025, time.February, 26),
+			want: []time.Time{
+				date(2025, time.March, 1),
+				date(2025, time.March, 4),
+			},
+		},
+		{
+			name:  "weekdays only",
+			rule:  "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR",
+			start: date(2025, time.January, 9),
+			want: []time.Time{
+				date(2025, time.January, 10),
+				date(2025, time.January, 13),
+				date(2025, time.January, 14),
+			},
+		},
+		{
+			name:  "COUNT includes the first occurrence",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: date(2025, time.January, 1),
+			want: []time.Time{
+				date(2025, time.January, 2),
+				date(2025, time.January, 3),
+			},
+		},
+		{
+			name:  "UNTIL as a date includes that whole day",
+			rule:  "FREQ=WEEKLY;UNTIL=20250120",
+			start: date(2025, time.January, 6),
+			want: []time.Time{
+				date(2025, time.January, 13),
+				date(2025, time.January, 20),
+			},
+		},
+		{
+			name:  "UNTIL as a time is exact",
+			rule:  "FREQ=WEEKLY;UNTIL=20250120T090000Z",
+			start: date(2025, time.January, 6),
+			want: []time.Time{
+				date(2025, time.January, 13),
+			},
+		},
+		{
+			name:  "UNTIL with a numbered BYDAY",
+			rule:  "FREQ=MONTHLY;BYDAY=1MO;UNTIL=20250401",
+			start: date(2025, time.January, 6),
+			want: []time.Time{
+				date(2025, time.February, 3),
+				date(2025, time.March, 3),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.rule, err)
+			}
+
+			current, occurrence := tt.start, 1
+			for i, want := range tt.want {
+				next, ok := rule.Next(current, occurrence)
+				if !ok {
+					t.Fatalf("occurrence %d: series ended, want %s", i+2, want)
+				}
+				if !next.Equal(want) {
+					t.Fatalf("occurrence %d: got %s, want %s", i+2, next, want)
+				}
+				current, occurrence = next, occurrence+1
+			}
+
+			// Only the rules that end are expected to end here
+			if rule.Count == 0 && rule.Until == nil {
+				return
+			}
+			if next, ok := rule.Next(current, occurrence); ok {
+				t.Fatalf("series should have ended, got %s", next)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    string
+		wantErr bool
+	}{
+		{rule: "FREQ=DAILY", want: "FREQ=DAILY"},
+		{rule: "rrule:freq=weekly;byday=mo,fr", want: "FREQ=WEEKLY;BYDAY=MO,FR"},
+		{rule: "FREQ=MONTHLY;INTERVAL=1;BYDAY=-1FR", want: "FREQ=MONTHLY;BYDAY=-1FR"},
+		{rule: "COUNT=5;FREQ=YEARLY", want: "FREQ=YEARLY;COUNT=5"},
+		{rule: "FREQ=WEEKLY;UNTIL=20251231T235959Z", want: "FREQ=WEEKLY;UNTIL=20251231T235959Z"},
+		{rule: "", wantErr: true},
+		{rule: "INTERVAL=2", wantErr: true},
+		{rule: "FREQ=HOURLY", wantErr: true},
+		{rule: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+		{rule: "FREQ=DAILY;COUNT=-1", wantErr: true},
+		{rule: "FREQ=DAILY;COUNT=2;UNTIL=20250101", wantErr: true},
+		{rule: "FREQ=DAILY;FREQ=WEEKLY", wantErr: true},
+		{rule: "FREQ=DAILY;BYMONTH=1", wantErr: true},
+		{rule: "FREQ=YEARLY;BYDAY=MO", wantErr: true},
+		{rule: "FREQ=WEEKLY;BYDAY=1MO", wantErr: true},
+		{rule: "FREQ=MONTHLY;BYDAY=6MO", wantErr: true},
+		{rule: "FREQ=MONTHLY;BYDAY=0MO", wantErr: true},
+		{rule: "FREQ=MONTHLY;BYDAY=XX", wantErr: true},
+		{rule: "FREQ=WEEKLY;UNTIL=tomorrow", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %q, want an error", rule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := rule.String(); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
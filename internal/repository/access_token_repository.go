@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type AccessTokenRepository interface {
+	Create(token *domain.PersonalAccessToken) error
+	GetByUserID(userID uint) ([]domain.PersonalAccessToken, error)
+	GetByHash(hash string) (*domain.PersonalAccessToken, error)
+	Revoke(id, userID uint) (bool, error)
+	RevokeAllForUser(userID uint) (int64, error)
+	TouchLastUsed(id uint) error
+}
+
+type accessTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewAccessTokenRepository(db *gorm.DB) AccessTokenRepository {
+	return &accessTokenRepository{db: db}
+}
+
+func (r *accessTokenRepository) Create(token *domain.PersonalAccessToken) error {
+	return r.db.Create(token).Error
+}
+
+func (r *accessTokenRepository) GetByUserID(userID uint) ([]domain.PersonalAccessToken, error) {
+	var tokens []domain.PersonalAccessToken
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL", userID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (r *accessTokenRepository) GetByHash(hash string) (*domain.PersonalAccessToken, error) {
+	var token domain.PersonalAccessToken
+	err := r.db.Where("token_hash = ?", hash).Preload("User").First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Revoke revokes a token owned by the user. It reports false when no active
+// token matched.
+func (r *accessTokenRepository) Revoke(id, userID uint) (bool, error) {
+	result := r.db.Model(&domain.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeAllForUser revokes every active token of the user.
+func (r *accessTokenRepository) RevokeAllForUser(userID uint) (int64, error) {
+	result := r.db.Model(&domain.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+func (r *accessTokenRepository) TouchLastUsed(id uint) error {
+	return r.db.Model(&domain.PersonalAccessToken{}).Where("id = ?", id).Update("last_used_at", time.Now()).Error
+}
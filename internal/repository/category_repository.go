@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/iskhakmuhamad/todo-api/internal/domain"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type CategoryRepository interface {
@@ -11,7 +14,11 @@ type CategoryRepository interface {
 	GetByUserID(userID uint) ([]domain.Category, error)
 	GetByID(id, userID uint) (*domain.Category, error)
 	Update(category *domain.Category) error
-	Delete(id, userID uint) error
+	Delete(category *domain.Category, strategy domain.CategoryDeleteStrategy, targetID *uint) (int64, error)
+	GetDeleted(userID uint) ([]domain.Category, error)
+	Restore(id, userID uint) error
+	Purge(id, userID uint) error
+	PurgeDeletedBefore(before time.Time) (int64, error)
 }
 
 type categoryRepository struct {
@@ -41,10 +48,157 @@ func (r *categoryRepository) GetByID(id, userID uint) (*domain.Category, error)
 	return &category, nil
 }
 
+// Update saves the category if the row is still at category.Version, and
+// bumps the version.
 func (r *categoryRepository) Update(category *domain.Category) error {
-	return r.db.Save(category).Error
+	expected := category.Version
+	category.Version++
+
+	result := r.db.Model(category).
+		Where("version = ?", expected).
+		Select("*").Omit("created_at", clause.Associations).
+		Updates(category)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = ErrStaleVersion
+	}
+	if result.Error != nil {
+		category.Version = expected
+	}
+	return result.Error
+}
+
+// Delete moves the category to the trash and applies strategy to its todos,
+// in one transaction. It returns how many todos were changed. Detaching and
+// moving also cover todos already in the trash, so none is left pointing at
+// the deleted category. Deleting todos also trashes their subtasks, filed
+// under any category, with the same deleted_at so they are restored and
+// purged together. The move target is locked for the transaction, and
+// ErrTargetNotFound means it is not one of the user's categories (any more).
+func (r *categoryRepository) Delete(category *domain.Category, strategy domain.CategoryDeleteStrategy, targetID *uint) (int64, error) {
+	var affected int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if strategy == domain.DeleteStrategyMove {
+			var target []uint
+			err := tx.Model(&domain.Category{}).
+				Where("id = ? AND user_id = ?", targetID, category.UserID).
+				Clauses(clause.Locking{Strength: "UPDATE"}).
+				Pluck("id", &target).Error
+			if err != nil {
+				return err
+			}
+			if len(target) == 0 {
+				return ErrTargetNotFound
+			}
+		}
+
+		result := tx.Where("id = ? AND user_id = ? AND version = ?", category.ID, category.UserID, category.Version).
+			Delete(&domain.Category{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStaleVersion
+		}
+
+		todos := tx.Model(&domain.Todo{}).Where("category_id = ? AND user_id = ?", category.ID, category.UserID)
+		switch strategy {
+		case domain.DeleteStrategyDelete:
+			parents := tx.Model(&domain.Todo{}).Select("id").Where("category_id = ? AND user_id = ?", category.ID, category.UserID)
+			result = tx.Model(&domain.Todo{}).
+				Where("user_id = ?", category.UserID).
+				Where(tx.Where("category_id = ?", category.ID).Or("parent_id IN (?)", parents)).
+				UpdateColumn("deleted_at", tx.NowFunc())
+		case domain.DeleteStrategyMove:
+			result = todos.Unscoped().Updates(map[string]interface{}{
+				"category_id": targetID,
+				"version":     gorm.Expr("version + 1"),
+			})
+		default:
+			result = todos.Unscoped().Updates(map[string]interface{}{
+				"category_id": nil,
+				"version":     gorm.Expr("version + 1"),
+			})
+		}
+		affected = result.RowsAffected
+		return result.Error
+	})
+	return affected, err
+}
+
+// GetDeleted lists the user's categories in the trash, most recently deleted
+// first.
+func (r *categoryRepository) GetDeleted(userID uint) ([]domain.Category, error) {
+	var categories []domain.Category
+	err := r.db.Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Order("deleted_at DESC").
+		Find(&categories).Error
+	return categories, err
+}
+
+// Restore takes a category out of the trash. Todos that still point at it
+// show up under it again.
+func (r *categoryRepository) Restore(id, userID uint) error {
+	result := r.db.Unscoped().Model(&domain.Category{}).
+		Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", id, userID).
+		Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// Purge permanently deletes a category that is in the trash. Todos filed
+// under it, including deleted ones, lose their category first, as the
+// foreign key requires.
+func (r *categoryRepository) Purge(id, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		trashed := tx.Unscoped().Model(&domain.Category{}).Select("id").
+			Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", id, userID)
+		if err := detachTodos(tx, trashed); err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().
+			Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", id, userID).
+			Delete(&domain.Category{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// PurgeDeletedBefore permanently deletes every category that went to the
+// trash before the given time.
+func (r *categoryRepository) PurgeDeletedBefore(before time.Time) (int64, error) {
+	var purged int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		expired := tx.Unscoped().Model(&domain.Category{}).Select("id").Where("deleted_at < ?", before)
+		if err := detachTodos(tx, expired); err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Where("deleted_at < ?", before).Delete(&domain.Category{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
 }
 
-func (r *categoryRepository) Delete(id, userID uint) error {
-	return r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&domain.Category{}).Error
+// detachTodos clears the category of every todo filed under one of the
+// given categories. categoryIDs is a slice of IDs or a subquery.
+func detachTodos(tx *gorm.DB, categoryIDs interface{}) error {
+	return tx.Unscoped().Model(&domain.Todo{}).
+		Where("category_id IN (?)", categoryIDs).
+		Updates(map[string]interface{}{
+			"category_id": nil,
+			"version":     gorm.Expr("version + 1"),
+		}).Error
 }
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrStaleVersion is returned by versioned updates when the row was changed
+// since it was read.
+var ErrStaleVersion = errors.New("record has been modified since it was read")
+
+// ErrDependencyCycle is returned when a new dependency would make a todo
+// wait for itself.
+var ErrDependencyCycle = errors.New("dependency would create a cycle")
+
+// ErrTargetNotFound is returned when the category todos are moved to no
+// longer exists.
+var ErrTargetNotFound = errors.New("target category not found")
+
+// ErrCategoryInTrash is returned when a todo cannot be restored because its
+// category is still in the trash.
+var ErrCategoryInTrash = errors.New("category is in the trash")
+
+// ErrSubtasksChanged is returned when a new order of subtasks does not list
+// the todo's subtasks exactly once each.
+var ErrSubtasksChanged = errors.New("ids do not match the subtasks")
+
+// ErrNameTaken is returned when a unique name is already used by another of
+// the user's records.
+var ErrNameTaken = errors.New("name is already taken")
+
+// isDuplicate reports whether err is a unique constraint violation.
+func isDuplicate(db *gorm.DB, err error) bool {
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
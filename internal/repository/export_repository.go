@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ExportRepository interface {
+	Create(job *domain.ExportJob) error
+	GetByID(id, userID uint) (*domain.ExportJob, error)
+	GetActiveByUserID(userID uint) (*domain.ExportJob, error)
+	GetByUserID(userID uint) ([]domain.ExportJob, error)
+	GetPending() ([]domain.ExportJob, error)
+	Claim(id uint) (*domain.ExportJob, error)
+	ResetRunning() error
+	GetExpired(now time.Time) ([]domain.ExportJob, error)
+	Update(job *domain.ExportJob) error
+	LoadUserData(userID uint) (*domain.UserData, error)
+}
+
+type exportRepository struct {
+	db *gorm.DB
+}
+
+func NewExportRepository(db *gorm.DB) ExportRepository {
+	return &exportRepository{db: db}
+}
+
+func (r *exportRepository) Create(job *domain.ExportJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *exportRepository) GetByID(id, userID uint) (*domain.ExportJob, error) {
+	var job domain.ExportJob
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *exportRepository) GetActiveByUserID(userID uint) (*domain.ExportJob, error) {
+	var job domain.ExportJob
+	err := r.db.Where("user_id = ? AND status IN ?", userID, []domain.ExportStatus{domain.ExportStatusPending, domain.ExportStatusRunning}).
+		First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *exportRepository) GetByUserID(userID uint) ([]domain.ExportJob, error) {
+	var jobs []domain.ExportJob
+	err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&jobs).Error
+	return jobs, err
+}
+
+func (r *exportRepository) GetPending() ([]domain.ExportJob, error) {
+	var jobs []domain.ExportJob
+	err := r.db.Where("status = ?", domain.ExportStatusPending).Order("id ASC").Find(&jobs).Error
+	return jobs, err
+}
+
+// Claim moves a pending job to running and returns it. It returns
+// gorm.ErrRecordNotFound when the job was already picked up.
+func (r *exportRepository) Claim(id uint) (*domain.ExportJob, error) {
+	var jobs []domain.ExportJob
+	err := r.db.Model(&jobs).Clauses(clause.Returning{}).
+		Where("id = ? AND status = ?", id, domain.ExportStatusPending).
+		Update("status", domain.ExportStatusRunning).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &jobs[0], nil
+}
+
+// ResetRunning puts jobs that were interrupted by a restart back in the queue.
+func (r *exportRepository) ResetRunning() error {
+	return r.db.Model(&domain.ExportJob{}).
+		Where("status = ?", domain.ExportStatusRunning).
+		Update("status", domain.ExportStatusPending).Error
+}
+
+func (r *exportRepository) GetExpired(now time.Time) ([]domain.ExportJob, error) {
+	var jobs []domain.ExportJob
+	err := r.db.Where("status = ? AND expires_at < ?", domain.ExportStatusCompleted, now).Find(&jobs).Error
+	return jobs, err
+}
+
+func (r *exportRepository) Update(job *domain.ExportJob) error {
+	return r.db.Save(job).Error
+}
+
+// LoadUserData collects everything stored for the user. Soft-deleted todos
+// and categories are included.
+func (r *exportRepository) LoadUserData(userID uint) (*domain.UserData, error) {
+	var data domain.UserData
+
+	if err := r.db.First(&data.Profile, userID).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Unscoped().Where("user_id = ?", userID).Order("id ASC").Find(&data.Categories).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("user_id = ?", userID).Order("id ASC").Find(&data.Tags).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Unscoped().Where("user_id = ?", userID).Preload("Tags").Order("id ASC").Find(&data.Todos).Error; err != nil {
+		return nil, err
+	}
+	todoIDs := r.db.Unscoped().Model(&domain.Todo{}).Select("id").Where("user_id = ?", userID)
+	if err := r.db.Where("todo_id IN (?)", todoIDs).Order("todo_id ASC, blocker_id ASC").Find(&data.Dependencies).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("user_id = ?", userID).Order("id ASC").Find(&data.Sessions).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("user_id = ?", userID).Order("id ASC").Find(&data.AccessTokens).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("user_id = ?", userID).Order("id ASC").Find(&data.ExternalIdentities).Error; err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
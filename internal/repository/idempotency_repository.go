@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type IdempotencyRepository interface {
+	Reserve(key *domain.IdempotencyKey, staleBefore time.Time) (bool, error)
+	Get(userID uint, key string) (*domain.IdempotencyKey, error)
+	Complete(key *domain.IdempotencyKey) error
+	Release(key *domain.IdempotencyKey) error
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// Reserve claims the key for a new request. It returns false when the key is
+// already held by a live record. Expired records, and in-flight records
+// started before staleBefore (left behind by a crash), are taken over.
+func (r *idempotencyRepository) Reserve(key *domain.IdempotencyKey, staleBefore time.Time) (bool, error) {
+	result := r.db.Raw(`
+		INSERT INTO idempotency_keys (user_id, key, request_hash, status_code, expires_at, created_at)
+		VALUES (?, ?, ?, 0, ?, ?)
+		ON CONFLICT (user_id, key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			status_code = 0,
+			content_type = NULL,
+			etag = NULL,
+			body = NULL,
+			expires_at = EXCLUDED.expires_at,
+			created_at = EXCLUDED.created_at
+		WHERE idempotency_keys.expires_at < EXCLUDED.created_at
+			OR (idempotency_keys.status_code = 0 AND idempotency_keys.created_at < ?)
+		RETURNING id`,
+		key.UserID, key.Key, key.RequestHash, key.ExpiresAt, key.CreatedAt, staleBefore,
+	).Scan(&key.ID)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *idempotencyRepository) Get(userID uint, key string) (*domain.IdempotencyKey, error) {
+	var record domain.IdempotencyKey
+	err := r.db.Where("user_id = ? AND key = ?", userID, key).First(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Complete stores the response of the request holding the key.
+func (r *idempotencyRepository) Complete(key *domain.IdempotencyKey) error {
+	return r.db.Model(key).Updates(map[string]interface{}{
+		"status_code":  key.StatusCode,
+		"content_type": key.ContentType,
+		"etag":         key.ETag,
+		"body":         key.Body,
+	}).Error
+}
+
+// Release frees the key so the request can be retried.
+func (r *idempotencyRepository) Release(key *domain.IdempotencyKey) error {
+	return r.db.Where("id = ? AND status_code = 0", key.ID).Delete(&domain.IdempotencyKey{}).Error
+}
+
+func (r *idempotencyRepository) DeleteExpired(now time.Time) (int64, error) {
+	result := r.db.Where("expires_at < ?", now).Delete(&domain.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdentityRepository interface {
+	Create(identity *domain.ExternalIdentity) error
+	GetByProviderSubject(provider, subject string) (*domain.ExternalIdentity, error)
+	CreateState(state *domain.OAuthState) error
+	ConsumeState(hash string) (*domain.OAuthState, error)
+}
+
+type identityRepository struct {
+	db *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) IdentityRepository {
+	return &identityRepository{db: db}
+}
+
+func (r *identityRepository) Create(identity *domain.ExternalIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+func (r *identityRepository) GetByProviderSubject(provider, subject string) (*domain.ExternalIdentity, error) {
+	var identity domain.ExternalIdentity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *identityRepository) CreateState(state *domain.OAuthState) error {
+	// Drop abandoned flows while we are here
+	r.db.Where("expires_at < ?", time.Now()).Delete(&domain.OAuthState{})
+	return r.db.Create(state).Error
+}
+
+// ConsumeState deletes and returns the state so it can only be used once.
+func (r *identityRepository) ConsumeState(hash string) (*domain.OAuthState, error) {
+	var states []domain.OAuthState
+	err := r.db.Clauses(clause.Returning{}).Where("state_hash = ?", hash).Delete(&states).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &states[0], nil
+}
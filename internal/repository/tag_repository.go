@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"strings"
+
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+
+	"gorm.io/gorm"
+)
+
+type TagRepository interface {
+	Create(tag *domain.Tag) error
+	GetByUserID(userID uint) ([]domain.Tag, error)
+	GetByID(id, userID uint) (*domain.Tag, error)
+	GetByIDs(ids []uint, userID uint) ([]domain.Tag, error)
+	GetByName(name string, userID uint) (*domain.Tag, error)
+	Update(tag *domain.Tag) error
+	Delete(tag *domain.Tag) error
+	Merge(source, target *domain.Tag) (int64, error)
+}
+
+type tagRepository struct {
+	db *gorm.DB
+}
+
+func NewTagRepository(db *gorm.DB) TagRepository {
+	return &tagRepository{db: db}
+}
+
+// Create inserts the tag. It returns ErrNameTaken when the user already has
+// a tag with the name, ignoring case.
+func (r *tagRepository) Create(tag *domain.Tag) error {
+	err := r.db.Create(tag).Error
+	if isDuplicate(r.db, err) {
+		return ErrNameTaken
+	}
+	return err
+}
+
+// GetByUserID lists the user's tags by name, with the number of todos
+// (outside the trash) that have each one.
+func (r *tagRepository) GetByUserID(userID uint) ([]domain.Tag, error) {
+	var tags []domain.Tag
+	err := r.db.
+		Select("tags.*, (?) AS todo_count", r.db.Table("todo_tags").
+			Select("COUNT(*)").
+			Joins("JOIN todos ON todos.id = todo_tags.todo_id AND todos.deleted_at IS NULL").
+			Where("todo_tags.tag_id = tags.id")).
+		Where("user_id = ?", userID).
+		Order("LOWER(name) ASC").
+		Find(&tags).Error
+	return tags, err
+}
+
+func (r *tagRepository) GetByID(id, userID uint) (*domain.Tag, error) {
+	var tag domain.Tag
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&tag).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// GetByIDs returns those of the given tags that belong to the user.
+func (r *tagRepository) GetByIDs(ids []uint, userID uint) ([]domain.Tag, error) {
+	var tags []domain.Tag
+	if len(ids) == 0 {
+		return tags, nil
+	}
+	err := r.db.Where("id IN ? AND user_id = ?", ids, userID).Order("LOWER(name) ASC").Find(&tags).Error
+	return tags, err
+}
+
+// GetByName looks a tag up by name, ignoring case.
+func (r *tagRepository) GetByName(name string, userID uint) (*domain.Tag, error) {
+	var tag domain.Tag
+	err := r.db.Where("LOWER(name) = ? AND user_id = ?", strings.ToLower(name), userID).First(&tag).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// Update saves the tag if the row is still at tag.Version, and bumps the
+// version of the tag and of every todo that has it, whose representation
+// includes the tag. It returns ErrNameTaken when another of the user's tags
+// has the new name.
+func (r *tagRepository) Update(tag *domain.Tag) error {
+	expected := tag.Version
+	tag.Version++
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(tag).
+			Where("version = ?", expected).
+			Select("*").Omit("created_at").
+			Updates(tag)
+		if isDuplicate(tx, result.Error) {
+			return ErrNameTaken
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStaleVersion
+		}
+
+		_, err := touchTaggedTodos(tx, tag.ID)
+		return err
+	})
+	if err != nil {
+		tag.Version = expected
+	}
+	return err
+}
+
+// Delete removes the tag from every todo and deletes it. Tags have no trash.
+func (r *tagRepository) Delete(tag *domain.Tag) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := touchTaggedTodos(tx, tag.ID); err != nil {
+			return err
+		}
+
+		result := tx.Where("id = ? AND user_id = ? AND version = ?", tag.ID, tag.UserID, tag.Version).
+			Delete(&domain.Tag{})
+		if result.Error == nil && result.RowsAffected == 0 {
+			return ErrStaleVersion
+		}
+		return result.Error
+	})
+}
+
+// Merge puts target on every todo that has source, then deletes source, in
+// one transaction. It returns how many todos had source.
+func (r *tagRepository) Merge(source, target *domain.Tag) (int64, error) {
+	var affected int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec(
+			`INSERT INTO todo_tags (todo_id, tag_id)
+			SELECT todo_id, ? FROM todo_tags WHERE tag_id = ?
+			ON CONFLICT DO NOTHING`,
+			target.ID, source.ID,
+		)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var err error
+		if affected, err = touchTaggedTodos(tx, source.ID); err != nil {
+			return err
+		}
+
+		// Deleting source also drops its todo_tags rows
+		result = tx.Where("id = ? AND user_id = ? AND version = ?", source.ID, source.UserID, source.Version).
+			Delete(&domain.Tag{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStaleVersion
+		}
+		return nil
+	})
+	return affected, err
+}
+
+// touchTaggedTodos bumps the version of every todo that has the tag, so
+// their ETags change along with the tag. It returns how many todos have it.
+func touchTaggedTodos(tx *gorm.DB, tagID uint) (int64, error) {
+	result := tx.Model(&domain.Todo{}).Unscoped().
+		Where("id IN (?)", tx.Model(&domain.TodoTag{}).Select("todo_id").Where("tag_id = ?", tagID)).
+		Update("version", gorm.Expr("version + 1"))
+	return result.RowsAffected, result.Error
+}
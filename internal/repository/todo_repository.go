@@ -1,19 +1,62 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/lexorank"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TodoRepository interface {
 	Create(todo *domain.Todo) error
 	GetByUserID(userID uint, filter domain.TodoFilter) ([]domain.Todo, int64, error)
 	GetByID(id, userID uint) (*domain.Todo, error)
+	GetByIDs(ids []uint, userID uint) ([]domain.Todo, error)
 	Update(todo *domain.Todo) error
-	Delete(id, userID uint) error
+	Delete(todo *domain.Todo) error
+	CountByUserIDs(userIDs []uint) (map[uint]domain.TodoCounts, error)
+	BulkUpdate(userID uint, ids []uint, filter *domain.TodoFilter, updates map[string]interface{}) ([]uint, []uint, error)
+	BulkDelete(userID uint, ids []uint, filter *domain.TodoFilter) ([]uint, error)
+	GetDeleted(userID uint) ([]domain.Todo, error)
+	Restore(id, userID uint) error
+	Purge(id, userID uint) error
+	PurgeDeletedBefore(before time.Time) (int64, error)
+	GetDeletedByID(id, userID uint) (*domain.Todo, error)
+	GetSubtasks(parentID, userID uint) ([]domain.Todo, error)
+	ReorderSubtasks(parentID uint, ids []uint) error
+	CompleteSubtasks(parentID uint) ([]uint, error)
+	HasOccurrence(seriesID uint, occurrence int) (bool, error)
+	SetTags(todoID uint, tagIDs []uint) error
+	AddDependency(userID, todoID, blockerID uint) error
+	RemoveDependency(todoID, blockerID uint) error
+	GetDependencies(todoID uint) (*domain.TodoDependencies, error)
+	GetOpen(userID uint) ([]domain.Todo, error)
+	Move(todo *domain.Todo, anchorID uint, after bool) error
+	Transaction(fn func(repo TodoRepository) error) error
 }
 
+// manualOrder sorts todos by rank. Ranks are compared byte by byte whatever
+// the database collation; todos without one come first, newest first.
+const manualOrder = `rank COLLATE "C" ASC, created_at DESC, id DESC`
+
+// subtaskOrder sorts the subtasks of a todo. Subtasks are ordered by
+// position rather than rank, whichever endpoint lists or moves them.
+const subtaskOrder = "position ASC, id ASC"
+
+// maxRankLength bounds how long ranks get before a list is renumbered.
+const maxRankLength = 64
+
+// hasOpenBlocker matches todos that wait for an open todo. Blockers in the
+// trash do not count.
+const hasOpenBlocker = `EXISTS (
+	SELECT 1 FROM todo_dependencies
+	JOIN todos AS blockers ON blockers.id = todo_dependencies.blocker_id
+	WHERE todo_dependencies.todo_id = todos.id AND blockers.status <> ? AND blockers.deleted_at IS NULL
+)`
+
 type todoRepository struct {
 	db *gorm.DB
 }
@@ -22,17 +65,95 @@ func NewTodoRepository(db *gorm.DB) TodoRepository {
 	return &todoRepository{db: db}
 }
 
+// Transaction runs fn with a repository whose reads and writes all happen in
+// one transaction, which is committed if fn returns nil and rolled back
+// otherwise.
+func (r *todoRepository) Transaction(fn func(repo TodoRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&todoRepository{db: tx})
+	})
+}
+
+// Create inserts the todo at the top of its category's manual order, or a
+// subtask after its parent's last subtask.
 func (r *todoRepository) Create(todo *domain.Todo) error {
-	return r.db.Create(todo).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(tx, todo.UserID); err != nil {
+			return err
+		}
+
+		todo.Rank = ""
+		if todo.ParentID != nil {
+			// Subtasks added at the same time must not share a position
+			if err := lockTodo(tx, *todo.ParentID); err != nil {
+				return err
+			}
+			err := tx.Model(&domain.Todo{}).
+				Select("COALESCE(MAX(position), 0) + 1").
+				Where("parent_id = ?", *todo.ParentID).
+				Scan(&todo.Position).Error
+			if err != nil {
+				return err
+			}
+			return tx.Create(todo).Error
+		}
+
+		var first []string
+		err := todoList(tx, todo.UserID, todo.CategoryID).Order(manualOrder).Limit(1).Pluck("rank", &first).Error
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case len(first) == 0:
+			todo.Rank, _ = lexorank.Between("", "")
+		case first[0] != "":
+			// Without room at the top the todo is left unranked, which
+			// still puts it first
+			if rank, err := lexorank.Between("", first[0]); err == nil && len(rank) <= maxRankLength {
+				todo.Rank = rank
+			}
+		}
+
+		return tx.Create(todo).Error
+	})
 }
 
 func (r *todoRepository) GetByUserID(userID uint, filter domain.TodoFilter) ([]domain.Todo, int64, error) {
 	var todos []domain.Todo
 	var total int64
 
-	query := r.db.Model(&domain.Todo{}).Where("user_id = ?", userID)
+	query := applyTodoFilter(r.db.Model(&domain.Todo{}).Where("user_id = ?", userID), filter)
+
+	// Count total
+	query.Count(&total)
+
+	// Apply pagination
+	if filter.Page > 0 && filter.Limit > 0 {
+		offset := (filter.Page - 1) * filter.Limit
+		query = query.Offset(offset).Limit(filter.Limit)
+	}
+
+	order := "created_at DESC"
+	switch {
+	case filter.Sort == domain.SortManual && filter.ParentID > 0:
+		order = subtaskOrder
+	case filter.Sort == domain.SortManual:
+		order = manualOrder
+	}
+
+	err := query.Preload("Category").Preload("Tags", orderTags).Order(order).Find(&todos).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.loadProgress(todos); err != nil {
+		return nil, 0, err
+	}
+	return todos, total, r.loadBlockers(todos)
+}
 
-	// Apply filters
+func applyTodoFilter(query *gorm.DB, filter domain.TodoFilter) *gorm.DB {
 	if filter.Status != "" {
 		query = query.Where("status = ?", filter.Status)
 	}
@@ -42,36 +163,826 @@ func (r *todoRepository) GetByUserID(userID uint, filter domain.TodoFilter) ([]d
 	if filter.CategoryID > 0 {
 		query = query.Where("category_id = ?", filter.CategoryID)
 	}
+	if filter.ParentID > 0 {
+		query = query.Where("parent_id = ?", filter.ParentID)
+	}
 	if filter.Keyword != "" {
 		query = query.Where("title ILIKE ? OR description ILIKE ?", "%"+filter.Keyword+"%", "%"+filter.Keyword+"%")
 	}
+	if len(filter.TagsAny) > 0 {
+		query = query.Where("id IN (?)", taggedTodos(query, filter.TagsAny))
+	}
+	if tags := uniqueIDs(filter.TagsAll); len(tags) > 0 {
+		query = query.Where("id IN (?)", taggedTodos(query, tags).Group("todo_id").Having("COUNT(*) = ?", len(tags)))
+	}
+	if len(filter.TagsNone) > 0 {
+		query = query.Where("id NOT IN (?)", taggedTodos(query, filter.TagsNone))
+	}
+	if filter.Blocked != nil {
+		if *filter.Blocked {
+			query = query.Where(hasOpenBlocker, domain.StatusDone)
+		} else {
+			query = query.Where("NOT "+hasOpenBlocker, domain.StatusDone)
+		}
+	}
+	if !filter.IncludeSubtasks && filter.ParentID == 0 {
+		query = query.Where("parent_id IS NULL")
+	}
+	return query
+}
 
-	// Count total
-	query.Count(&total)
+// taggedTodos selects the IDs of todos that have any of the tags.
+func taggedTodos(query *gorm.DB, tagIDs []uint) *gorm.DB {
+	return query.Session(&gorm.Session{NewDB: true}).
+		Model(&domain.TodoTag{}).Select("todo_id").Where("tag_id IN ?", tagIDs)
+}
 
-	// Apply pagination
-	if filter.Page > 0 && filter.Limit > 0 {
-		offset := (filter.Page - 1) * filter.Limit
-		query = query.Offset(offset).Limit(filter.Limit)
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
 	}
+	return unique
+}
 
-	err := query.Preload("Category").Order("created_at DESC").Find(&todos).Error
-	return todos, total, err
+func orderTags(db *gorm.DB) *gorm.DB {
+	return db.Order("LOWER(name) ASC")
 }
 
 func (r *todoRepository) GetByID(id, userID uint) (*domain.Todo, error) {
 	var todo domain.Todo
-	err := r.db.Where("id = ? AND user_id = ?", id, userID).Preload("Category").First(&todo).Error
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).Preload("Category").Preload("Tags", orderTags).First(&todo).Error
 	if err != nil {
 		return nil, err
 	}
-	return &todo, nil
+
+	todos := []domain.Todo{todo}
+	if err := r.loadProgress(todos); err != nil {
+		return nil, err
+	}
+	if err := r.loadBlockers(todos); err != nil {
+		return nil, err
+	}
+	return &todos[0], nil
+}
+
+// GetByIDs returns those of the given todos that belong to the user, by ID.
+func (r *todoRepository) GetByIDs(ids []uint, userID uint) ([]domain.Todo, error) {
+	var todos []domain.Todo
+	if len(ids) == 0 {
+		return todos, nil
+	}
+
+	err := r.db.Where("id IN ? AND user_id = ?", ids, userID).
+		Preload("Category").
+		Preload("Tags", orderTags).
+		Order("id ASC").
+		Find(&todos).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadProgress(todos); err != nil {
+		return nil, err
+	}
+	return todos, r.loadBlockers(todos)
 }
 
+// Update saves the todo's own columns if the row is still at todo.Version,
+// and bumps the version. Preloaded relations are not written back, so
+// changing CategoryID is not undone by a stale Category.
 func (r *todoRepository) Update(todo *domain.Todo) error {
-	return r.db.Save(todo).Error
+	expected := todo.Version
+	todo.Version++
+
+	result := r.db.Model(todo).
+		Where("version = ?", expected).
+		Select("*").Omit("created_at", clause.Associations).
+		Updates(todo)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = ErrStaleVersion
+	}
+	if result.Error != nil {
+		todo.Version = expected
+	}
+	return result.Error
+}
+
+// Delete moves the todo and its subtasks to the trash, if the todo is still
+// at todo.Version. They share the same deleted_at, so restoring the todo
+// brings the subtasks back too.
+func (r *todoRepository) Delete(todo *domain.Todo) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ? AND version = ?", todo.ID, todo.UserID, todo.Version).
+			Delete(&domain.Todo{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStaleVersion
+		}
+
+		deletedAt := tx.Unscoped().Model(&domain.Todo{}).Select("deleted_at").Where("id = ?", todo.ID)
+		return tx.Model(&domain.Todo{}).
+			Where("parent_id = ? AND user_id = ?", todo.ID, todo.UserID).
+			Update("deleted_at", gorm.Expr("(?)", deletedAt)).Error
+	})
+}
+
+func (r *todoRepository) CountByUserIDs(userIDs []uint) (map[uint]domain.TodoCounts, error) {
+	var rows []struct {
+		UserID uint
+		Total  int64
+		Done   int64
+	}
+
+	err := r.db.Model(&domain.Todo{}).
+		Select("user_id, COUNT(*) AS total, COUNT(*) FILTER (WHERE status = ?) AS done", domain.StatusDone).
+		Where("user_id IN ?", userIDs).
+		Group("user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]domain.TodoCounts, len(rows))
+	for _, row := range rows {
+		counts[row.UserID] = domain.TodoCounts{
+			Total: row.Total,
+			Done:  row.Done,
+			Open:  row.Total - row.Done,
+		}
+	}
+	return counts, nil
+}
+
+// BulkUpdate applies updates to the user's todos picked by ids or filter, in
+// one transaction, and returns the IDs that were changed. IDs the user does
+// not own are skipped. When updates completes todos, those still waiting for
+// an open todo are left alone and returned as blocked.
+func (r *todoRepository) BulkUpdate(userID uint, ids []uint, filter *domain.TodoFilter, updates map[string]interface{}) ([]uint, []uint, error) {
+	var updated, blocked []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		updated, err = lockTodos(tx, userID, ids, filter)
+		if err != nil || len(updated) == 0 {
+			return err
+		}
+
+		if updates["status"] == domain.StatusDone {
+			updated, blocked, err = splitBlocked(tx, updated)
+			if err != nil || len(updated) == 0 {
+				return err
+			}
+		}
+
+		updates["version"] = gorm.Expr("version + 1")
+		return tx.Model(&domain.Todo{}).Where("id IN ?", updated).Updates(updates).Error
+	})
+	return updated, blocked, err
+}
+
+// splitBlocked separates the todos that can be completed together from those
+// waiting for an open todo outside of ids, directly or through another
+// blocked todo.
+func splitBlocked(tx *gorm.DB, ids []uint) (free, blocked []uint, err error) {
+	dependencies, err := openBlockers(tx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	waitingFor := make(map[uint][]uint)
+	for _, dependency := range dependencies {
+		waitingFor[dependency.TodoID] = append(waitingFor[dependency.TodoID], dependency.BlockerID)
+	}
+
+	completing := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		completing[id] = true
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, id := range ids {
+			if !completing[id] {
+				continue
+			}
+			for _, blockerID := range waitingFor[id] {
+				if !completing[blockerID] {
+					completing[id] = false
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	for _, id := range ids {
+		if completing[id] {
+			free = append(free, id)
+		} else {
+			blocked = append(blocked, id)
+		}
+	}
+	return free, blocked, nil
+}
+
+// BulkDelete soft deletes the user's todos picked by ids or filter, along
+// with their subtasks, in one transaction, and returns the IDs that were
+// deleted.
+func (r *todoRepository) BulkDelete(userID uint, ids []uint, filter *domain.TodoFilter) ([]uint, error) {
+	var matched []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		matched, err = lockTodos(tx, userID, ids, filter)
+		if err != nil || len(matched) == 0 {
+			return err
+		}
+
+		return tx.Where("id IN ? OR parent_id IN ?", matched, matched).Delete(&domain.Todo{}).Error
+	})
+	return matched, err
+}
+
+// lockTodos selects the IDs of the user's todos matching ids or filter and
+// locks the rows until the transaction ends.
+func lockTodos(tx *gorm.DB, userID uint, ids []uint, filter *domain.TodoFilter) ([]uint, error) {
+	query := tx.Model(&domain.Todo{}).Where("user_id = ?", userID)
+	if filter != nil {
+		query = applyTodoFilter(query, *filter)
+	} else {
+		query = query.Where("id IN ?", ids)
+	}
+
+	var matched []uint
+	err := query.Clauses(clause.Locking{Strength: "UPDATE"}).Order("id").Pluck("id", &matched).Error
+	return matched, err
+}
+
+// GetDeleted lists the user's todos in the trash, most recently deleted
+// first.
+func (r *todoRepository) GetDeleted(userID uint) ([]domain.Todo, error) {
+	var todos []domain.Todo
+	err := r.db.Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+		Preload("Category").
+		Preload("Tags", orderTags).
+		Order("deleted_at DESC").
+		Find(&todos).Error
+	return todos, err
+}
+
+// Restore takes a todo out of the trash, together with the subtasks that were
+// deleted with it. It returns gorm.ErrRecordNotFound when the user has no
+// such todo in the trash, and ErrCategoryInTrash when one of the todos is
+// filed under a category that is.
+func (r *todoRepository) Restore(id, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		deletedAt := tx.Unscoped().Model(&domain.Todo{}).Select("deleted_at").Where("id = ?", id)
+		restoring := tx.Unscoped().Model(&domain.Todo{}).
+			Where("user_id = ? AND deleted_at IS NOT NULL", userID).
+			Where(tx.Where("id = ?", id).Or("parent_id = ? AND deleted_at = (?)", id, deletedAt))
+
+		var trashed int64
+		err := tx.Unscoped().Model(&domain.Category{}).
+			Where("deleted_at IS NOT NULL AND id IN (?)", restoring.Session(&gorm.Session{}).Select("category_id")).
+			Count(&trashed).Error
+		if err != nil {
+			return err
+		}
+		if trashed > 0 {
+			return ErrCategoryInTrash
+		}
+
+		result := restoring.Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		})
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
+}
+
+// Purge permanently deletes a todo that is in the trash, and its subtasks.
+func (r *todoRepository) Purge(id, userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().
+			Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", id, userID).
+			Delete(&domain.Todo{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Unscoped().Where("parent_id = ?", id).Delete(&domain.Todo{}).Error
+	})
+}
+
+// PurgeDeletedBefore permanently deletes every todo that went to the trash
+// before the given time.
+func (r *todoRepository) PurgeDeletedBefore(before time.Time) (int64, error) {
+	result := r.db.Unscoped().Where("deleted_at < ?", before).Delete(&domain.Todo{})
+	return result.RowsAffected, result.Error
+}
+
+// GetDeletedByID returns one of the user's todos from the trash.
+func (r *todoRepository) GetDeletedByID(id, userID uint) (*domain.Todo, error) {
+	var todo domain.Todo
+	err := r.db.Unscoped().Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", id, userID).First(&todo).Error
+	if err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+func (r *todoRepository) GetSubtasks(parentID, userID uint) ([]domain.Todo, error) {
+	var todos []domain.Todo
+	err := r.db.Where("parent_id = ? AND user_id = ?", parentID, userID).
+		Preload("Category").
+		Preload("Tags", orderTags).
+		Order(subtaskOrder).
+		Find(&todos).Error
+	return todos, err
+}
+
+// ReorderSubtasks numbers the subtasks in the order of ids, starting at 1.
+// It returns ErrSubtasksChanged unless ids lists every subtask of the todo
+// exactly once, which is checked under the parent's lock so a subtask added
+// in the meantime cannot be left out.
+func (r *todoRepository) ReorderSubtasks(parentID uint, ids []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockTodo(tx, parentID); err != nil {
+			return err
+		}
+
+		var subtasks []uint
+		if err := tx.Model(&domain.Todo{}).Where("parent_id = ?", parentID).Pluck("id", &subtasks).Error; err != nil {
+			return err
+		}
+		remaining := make(map[uint]bool, len(subtasks))
+		for _, id := range subtasks {
+			remaining[id] = true
+		}
+		for _, id := range ids {
+			if !remaining[id] {
+				return ErrSubtasksChanged
+			}
+			delete(remaining, id)
+		}
+		if len(remaining) > 0 {
+			return ErrSubtasksChanged
+		}
+
+		for i, id := range ids {
+			err := tx.Model(&domain.Todo{}).
+				Where("id = ? AND parent_id = ?", id, parentID).
+				Updates(map[string]interface{}{
+					"position": i + 1,
+					"version":  gorm.Expr("version + 1"),
+				}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CompleteSubtasks marks the open subtasks of the todo as done, and returns
+// their IDs. Subtasks still waiting for an open todo are left open, as in
+// BulkUpdate.
+func (r *todoRepository) CompleteSubtasks(parentID uint) ([]uint, error) {
+	var completed []uint
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.Todo{}).
+			Where("parent_id = ? AND status <> ?", parentID, domain.StatusDone).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Order("id").
+			Pluck("id", &completed).Error
+		if err != nil || len(completed) == 0 {
+			return err
+		}
+
+		completed, _, err = splitBlocked(tx, completed)
+		if err != nil || len(completed) == 0 {
+			return err
+		}
+
+		return tx.Model(&domain.Todo{}).Where("id IN ?", completed).Updates(map[string]interface{}{
+			"status":  domain.StatusDone,
+			"version": gorm.Expr("version + 1"),
+		}).Error
+	})
+	return completed, err
+}
+
+// HasOccurrence reports whether the series already has the given occurrence,
+// in the trash or not. The series is identified by its first todo.
+func (r *todoRepository) HasOccurrence(seriesID uint, occurrence int) (bool, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&domain.Todo{}).
+		Where("series_id = ? AND occurrence = ?", seriesID, occurrence).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// SetTags replaces the todo's tags with tagIDs.
+func (r *todoRepository) SetTags(todoID uint, tagIDs []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("todo_id = ?", todoID).Delete(&domain.TodoTag{}).Error; err != nil {
+			return err
+		}
+		if len(tagIDs) == 0 {
+			return nil
+		}
+
+		rows := make([]domain.TodoTag, len(tagIDs))
+		for i, tagID := range tagIDs {
+			rows[i] = domain.TodoTag{TodoID: todoID, TagID: tagID}
+		}
+		return tx.Create(&rows).Error
+	})
+}
+
+// AddDependency records that todoID waits for blockerID. It returns
+// ErrDependencyCycle when blockerID already waits for todoID, directly or
+// through other todos. Adding a dependency that exists is a no-op.
+func (r *todoRepository) AddDependency(userID, todoID, blockerID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Otherwise two concurrent additions could close a cycle between them
+		if err := lockUser(tx, userID); err != nil {
+			return err
+		}
+
+		var cycle bool
+		err := tx.Raw(`
+			WITH RECURSIVE upstream(id) AS (
+				SELECT blocker_id FROM todo_dependencies WHERE todo_id = ?
+				UNION
+				SELECT todo_dependencies.blocker_id FROM todo_dependencies
+				JOIN upstream ON todo_dependencies.todo_id = upstream.id
+			)
+			SELECT EXISTS (SELECT 1 FROM upstream WHERE id = ?)`,
+			blockerID, todoID,
+		).Scan(&cycle).Error
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrDependencyCycle
+		}
+
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).
+			Create(&domain.TodoDependency{TodoID: todoID, BlockerID: blockerID}).Error
+	})
+}
+
+// RemoveDependency deletes the dependency of todoID on blockerID. It returns
+// gorm.ErrRecordNotFound when there is none.
+func (r *todoRepository) RemoveDependency(todoID, blockerID uint) error {
+	result := r.db.Where("todo_id = ? AND blocker_id = ?", todoID, blockerID).Delete(&domain.TodoDependency{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+func (r *todoRepository) GetDependencies(todoID uint) (*domain.TodoDependencies, error) {
+	var dependencies domain.TodoDependencies
+
+	blockers := r.db.Model(&domain.TodoDependency{}).Select("blocker_id").Where("todo_id = ?", todoID)
+	err := r.db.Where("id IN (?)", blockers).
+		Preload("Category").
+		Preload("Tags", orderTags).
+		Order("id ASC").
+		Find(&dependencies.BlockedBy).Error
+	if err != nil {
+		return nil, err
+	}
+
+	blocking := r.db.Model(&domain.TodoDependency{}).Select("todo_id").Where("blocker_id = ?", todoID)
+	err = r.db.Where("id IN (?)", blocking).
+		Preload("Category").
+		Preload("Tags", orderTags).
+		Order("id ASC").
+		Find(&dependencies.Blocking).Error
+	if err != nil {
+		return nil, err
+	}
+	return &dependencies, nil
+}
+
+// GetOpen returns all of the user's todos that are not done, subtasks
+// included.
+func (r *todoRepository) GetOpen(userID uint) ([]domain.Todo, error) {
+	var todos []domain.Todo
+	err := r.db.Where("user_id = ? AND status <> ?", userID, domain.StatusDone).
+		Preload("Category").
+		Preload("Tags", orderTags).
+		Order("id ASC").
+		Find(&todos).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.loadProgress(todos); err != nil {
+		return nil, err
+	}
+	return todos, r.loadBlockers(todos)
+}
+
+// Move places the todo right before or after the anchor in its category's
+// manual order, if the todo is still at todo.Version. Usually only the todo's
+// rank changes. The list is renumbered when there is no room left between
+// the neighbours or it has todos that were never ranked. A subtask is moved
+// among its parent's subtasks by position instead, as ReorderSubtasks does.
+// ErrStaleVersion means the todo or the anchor changed since they were read.
+func (r *todoRepository) Move(todo *domain.Todo, anchorID uint, after bool) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Otherwise two concurrent moves into the same gap would get the
+		// same rank
+		if err := lockUser(tx, todo.UserID); err != nil {
+			return err
+		}
+		if todo.ParentID != nil {
+			return moveSubtask(tx, todo, anchorID, after)
+		}
+
+		var list []domain.Todo
+		err := todoList(tx, todo.UserID, todo.CategoryID).
+			Select("id", "rank", "version").
+			Order(manualOrder).
+			Find(&list).Error
+		if err != nil {
+			return err
+		}
+
+		// Take the todo out of the list, then find its new place
+		items := make([]domain.Todo, 0, len(list))
+		found, ranked := false, true
+		for _, item := range list {
+			if item.ID == todo.ID {
+				found = item.Version == todo.Version
+				continue
+			}
+			items = append(items, item)
+			ranked = ranked && item.Rank != ""
+		}
+		pos := -1
+		for i, item := range items {
+			if item.ID == anchorID {
+				pos = i
+			}
+		}
+		if !found || pos < 0 {
+			return ErrStaleVersion
+		}
+		if after {
+			pos++
+		}
+
+		prev, next := "", ""
+		if pos > 0 {
+			prev = items[pos-1].Rank
+		}
+		if pos < len(items) {
+			next = items[pos].Rank
+		}
+		if rank, err := lexorank.Between(prev, next); ranked && err == nil && len(rank) <= maxRankLength {
+			return setRank(tx, todo, rank)
+		}
+
+		items = append(items[:pos], append([]domain.Todo{{ID: todo.ID}}, items[pos:]...)...)
+		ranks := lexorank.Spread(len(items))
+		for i, item := range items {
+			if item.ID == todo.ID {
+				if err := setRank(tx, todo, ranks[i]); err != nil {
+					return err
+				}
+				continue
+			}
+			if item.Rank == ranks[i] {
+				continue
+			}
+			err := tx.Model(&domain.Todo{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+				"rank":    ranks[i],
+				"version": gorm.Expr("version + 1"),
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// setRank changes the todo's rank if it is still at todo.Version.
+func setRank(tx *gorm.DB, todo *domain.Todo, rank string) error {
+	result := tx.Model(&domain.Todo{}).
+		Where("id = ? AND version = ?", todo.ID, todo.Version).
+		Updates(map[string]interface{}{
+			"rank":    rank,
+			"version": gorm.Expr("version + 1"),
+		})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrStaleVersion
+	}
+	return result.Error
+}
+
+// moveSubtask renumbers the subtasks of the todo's parent with the todo
+// right before or after the anchor. Only subtasks whose position changes are
+// written, and the todo only if it is still at todo.Version.
+func moveSubtask(tx *gorm.DB, todo *domain.Todo, anchorID uint, after bool) error {
+	if err := lockTodo(tx, *todo.ParentID); err != nil {
+		return err
+	}
+
+	var list []domain.Todo
+	err := tx.Model(&domain.Todo{}).
+		Select("id", "position", "version").
+		Where("parent_id = ?", *todo.ParentID).
+		Order(subtaskOrder).
+		Find(&list).Error
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uint, len(list))
+	positions := make(map[uint]int, len(list))
+	for i, item := range list {
+		ids[i] = item.ID
+		positions[item.ID] = item.Position
+		if item.ID == todo.ID && item.Version != todo.Version {
+			return ErrStaleVersion
+		}
+	}
+	ids, ok := moveID(ids, todo.ID, anchorID, after)
+	if !ok {
+		return ErrStaleVersion
+	}
+
+	for i, id := range ids {
+		if positions[id] == i+1 && id != todo.ID {
+			continue
+		}
+		query := tx.Model(&domain.Todo{}).Where("id = ?", id)
+		if id == todo.ID {
+			query = query.Where("version = ?", todo.Version)
+		}
+		result := query.Updates(map[string]interface{}{
+			"position": i + 1,
+			"version":  gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrStaleVersion
+		}
+	}
+	return nil
+}
+
+// moveID takes id out of ids and puts it back right before or after
+// anchorID. It reports false when either of them is not in ids.
+func moveID(ids []uint, id, anchorID uint, after bool) ([]uint, bool) {
+	moved := make([]uint, 0, len(ids))
+	found := false
+	for _, other := range ids {
+		if other == id {
+			found = true
+			continue
+		}
+		moved = append(moved, other)
+	}
+
+	pos := -1
+	for i, other := range moved {
+		if other == anchorID {
+			pos = i
+		}
+	}
+	if !found || pos < 0 {
+		return nil, false
+	}
+	if after {
+		pos++
+	}
+
+	moved = append(moved, 0)
+	copy(moved[pos+1:], moved[pos:])
+	moved[pos] = id
+	return moved, true
+}
+
+// todoList selects the top-level todos that share a manual order: the
+// user's todos in one category, or those without a category.
+func todoList(tx *gorm.DB, userID uint, categoryID *uint) *gorm.DB {
+	query := tx.Model(&domain.Todo{}).Where("user_id = ?", userID).Where("parent_id IS NULL")
+	if categoryID == nil {
+		return query.Where("category_id IS NULL")
+	}
+	return query.Where("category_id = ?", *categoryID)
 }
 
-func (r *todoRepository) Delete(id, userID uint) error {
-	return r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&domain.Todo{}).Error
+// lockUser locks the user's row until the transaction ends, to serialize
+// changes that span several of the user's todos. NO KEY UPDATE does not
+// conflict with the key share locks taken by foreign key checks, so other
+// writes that reference the user are not held up.
+func lockUser(tx *gorm.DB, userID uint) error {
+	var locked []uint
+	return tx.Model(&domain.User{}).Where("id = ?", userID).
+		Clauses(clause.Locking{Strength: "NO KEY UPDATE"}).
+		Pluck("id", &locked).Error
+}
+
+// lockTodo locks the todo's row until the transaction ends, to serialize
+// changes to its subtasks.
+func lockTodo(tx *gorm.DB, id uint) error {
+	var locked []uint
+	return tx.Model(&domain.Todo{}).Where("id = ?", id).
+		Clauses(clause.Locking{Strength: "NO KEY UPDATE"}).
+		Pluck("id", &locked).Error
+}
+
+// loadBlockers fills in BlockedBy for the todos waiting for open todos.
+func (r *todoRepository) loadBlockers(todos []domain.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+	}
+
+	dependencies, err := openBlockers(r.db, ids)
+	if err != nil {
+		return err
+	}
+
+	blockedBy := make(map[uint][]uint)
+	for _, dependency := range dependencies {
+		blockedBy[dependency.TodoID] = append(blockedBy[dependency.TodoID], dependency.BlockerID)
+	}
+	for i := range todos {
+		todos[i].BlockedBy = blockedBy[todos[i].ID]
+	}
+	return nil
+}
+
+// openBlockers returns the dependencies of the given todos on todos that are
+// still open and not in the trash.
+func openBlockers(db *gorm.DB, ids []uint) ([]domain.TodoDependency, error) {
+	var dependencies []domain.TodoDependency
+	err := db.Model(&domain.TodoDependency{}).
+		Select("todo_dependencies.todo_id, todo_dependencies.blocker_id").
+		Joins("JOIN todos AS blockers ON blockers.id = todo_dependencies.blocker_id").
+		Where("todo_dependencies.todo_id IN ? AND blockers.status <> ? AND blockers.deleted_at IS NULL", ids, domain.StatusDone).
+		Order("todo_dependencies.blocker_id ASC").
+		Find(&dependencies).Error
+	return dependencies, err
+}
+
+// loadProgress fills in Progress for the todos that have subtasks.
+func (r *todoRepository) loadProgress(todos []domain.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(todos))
+	for i, todo := range todos {
+		ids[i] = todo.ID
+	}
+
+	var rows []struct {
+		ParentID uint
+		Total    int64
+		Done     int64
+	}
+	err := r.db.Model(&domain.Todo{}).
+		Select("parent_id, COUNT(*) AS total, COUNT(*) FILTER (WHERE status = ?) AS done", domain.StatusDone).
+		Where("parent_id IN ?", ids).
+		Group("parent_id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	progress := make(map[uint]*domain.TodoProgress, len(rows))
+	for _, row := range rows {
+		progress[row.ParentID] = &domain.TodoProgress{Done: row.Done, Total: row.Total}
+	}
+	for i := range todos {
+		todos[i].Progress = progress[todos[i].ID]
+	}
+	return nil
 }
@@ -0,0 +1,281 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB connects to the Postgres database in TEST_DATABASE_URL and migrates
+// it. Tests are skipped when it is not set.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	err = db.AutoMigrate(&domain.User{}, &domain.Category{}, &domain.Tag{}, &domain.Todo{}, &domain.TodoDependency{})
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// withTx runs fn in a transaction that is always rolled back, so tests leave
+// nothing behind.
+func withTx(t *testing.T, db *gorm.DB, fn func(tx *gorm.DB)) {
+	t.Helper()
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatalf("begin: %v", tx.Error)
+	}
+	defer tx.Rollback()
+	fn(tx)
+}
+
+// createUser inserts a user for the test to own todos.
+func createUser(t *testing.T, tx *gorm.DB, name string) domain.User {
+	t.Helper()
+
+	user := domain.User{Email: name + "@example.com", Username: name, Password: "x"}
+	if err := tx.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func TestAddDependency(t *testing.T) {
+	db := testDB(t)
+
+	// Edges are {todo, blocker} pairs of indexes into the todos a to e
+	tests := []struct {
+		name    string
+		edges   [][2]int
+		add     [2]int
+		wantErr error
+	}{
+		{
+			name: "independent todos",
+			add:  [2]int{0, 1},
+		},
+		{
+			name:  "existing dependency",
+			edges: [][2]int{{0, 1}},
+			add:   [2]int{0, 1},
+		},
+		{
+			name:  "second blocker",
+			edges: [][2]int{{0, 1}},
+			add:   [2]int{0, 2},
+		},
+		{
+			name:  "shortcut along a chain",
+			edges: [][2]int{{0, 1}, {1, 2}},
+			add:   [2]int{0, 2},
+		},
+		{
+			name:  "diamond",
+			edges: [][2]int{{0, 1}, {0, 2}, {1, 3}},
+			add:   [2]int{2, 3},
+		},
+		{
+			name:  "unrelated cycle elsewhere",
+			edges: [][2]int{{0, 1}, {1, 2}},
+			add:   [2]int{3, 4},
+		},
+		{
+			name:    "direct cycle",
+			edges:   [][2]int{{0, 1}},
+			add:     [2]int{1, 0},
+			wantErr: ErrDependencyCycle,
+		},
+		{
+			name:    "transitive cycle",
+			edges:   [][2]int{{0, 1}, {1, 2}},
+			add:     [2]int{2, 0},
+			wantErr: ErrDependencyCycle,
+		},
+		{
+			name:    "long transitive cycle",
+			edges:   [][2]int{{0, 1}, {1, 2}, {2, 3}, {3, 4}},
+			add:     [2]int{4, 0},
+			wantErr: ErrDependencyCycle,
+		},
+		{
+			name:    "transitive cycle through a branch",
+			edges:   [][2]int{{0, 1}, {1, 2}, {1, 3}, {3, 4}},
+			add:     [2]int{4, 0},
+			wantErr: ErrDependencyCycle,
+		},
+		{
+			name:    "cycle into the middle of a chain",
+			edges:   [][2]int{{0, 1}, {1, 2}, {2, 3}},
+			add:     [2]int{3, 1},
+			wantErr: ErrDependencyCycle,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withTx(t, db, func(tx *gorm.DB) {
+				user := createUser(t, tx, fmt.Sprintf("cycle-%d", i))
+
+				todos := make([]domain.Todo, 5)
+				for j := range todos {
+					todos[j] = domain.Todo{UserID: user.ID, Title: string(rune('a' + j))}
+				}
+				if err := tx.Omit("Tags").Create(&todos).Error; err != nil {
+					t.Fatalf("create todos: %v", err)
+				}
+
+				for _, edge := range tt.edges {
+					dependency := domain.TodoDependency{TodoID: todos[edge[0]].ID, BlockerID: todos[edge[1]].ID}
+					if err := tx.Create(&dependency).Error; err != nil {
+						t.Fatalf("create dependency: %v", err)
+					}
+				}
+
+				repo := NewTodoRepository(tx)
+				todoID, blockerID := todos[tt.add[0]].ID, todos[tt.add[1]].ID
+				err := repo.AddDependency(user.ID, todoID, blockerID)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+
+				var count int64
+				err = tx.Model(&domain.TodoDependency{}).
+					Where("todo_id = ? AND blocker_id = ?", todoID, blockerID).
+					Count(&count).Error
+				if err != nil {
+					t.Fatalf("count dependencies: %v", err)
+				}
+				want := int64(1)
+				if tt.wantErr != nil {
+					want = 0
+				}
+				if count != want {
+					t.Fatalf("got %d rows for the dependency, want %d", count, want)
+				}
+			})
+		})
+	}
+}
+
+func TestMoveID(t *testing.T) {
+	tests := []struct {
+		name     string
+		ids      []uint
+		id       uint
+		anchorID uint
+		after    bool
+		want     []uint
+	}{
+		{name: "before the first", ids: []uint{1, 2, 3}, id: 3, anchorID: 1, want: []uint{3, 1, 2}},
+		{name: "after the last", ids: []uint{1, 2, 3}, id: 1, anchorID: 3, after: true, want: []uint{2, 3, 1}},
+		{name: "before the next one", ids: []uint{1, 2, 3}, id: 1, anchorID: 2, want: []uint{1, 2, 3}},
+		{name: "after the next one", ids: []uint{1, 2, 3}, id: 1, anchorID: 2, after: true, want: []uint{2, 1, 3}},
+		{name: "into the middle", ids: []uint{1, 2, 3, 4}, id: 4, anchorID: 2, after: true, want: []uint{1, 2, 4, 3}},
+		{name: "missing todo", ids: []uint{1, 2}, id: 3, anchorID: 1},
+		{name: "missing anchor", ids: []uint{1, 2}, id: 1, anchorID: 3},
+		{name: "anchor is the todo", ids: []uint{1, 2}, id: 1, anchorID: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := moveID(tt.ids, tt.id, tt.anchorID, tt.after)
+			if ok != (tt.want != nil) {
+				t.Fatalf("got ok %v, want %v", ok, tt.want != nil)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) && tt.want != nil {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSubtaskOrder checks that the subtask list and the manual list of a
+// parent's subtasks agree, whether subtasks are moved or reordered.
+func TestSubtaskOrder(t *testing.T) {
+	db := testDB(t)
+
+	withTx(t, db, func(tx *gorm.DB) {
+		repo := NewTodoRepository(tx)
+		user := createUser(t, tx, "subtask-order")
+
+		parent := domain.Todo{UserID: user.ID, Title: "parent", Version: 1}
+		if err := repo.Create(&parent); err != nil {
+			t.Fatalf("create parent: %v", err)
+		}
+		subtasks := make([]domain.Todo, 4)
+		for i := range subtasks {
+			subtasks[i] = domain.Todo{UserID: user.ID, ParentID: &parent.ID, Title: fmt.Sprint(i), Version: 1}
+			if err := repo.Create(&subtasks[i]); err != nil {
+				t.Fatalf("create subtask: %v", err)
+			}
+		}
+		id := func(i int) uint { return subtasks[i].ID }
+
+		check := func(step string, want []uint) {
+			t.Helper()
+
+			listed, err := repo.GetSubtasks(parent.ID, user.ID)
+			if err != nil {
+				t.Fatalf("%s: get subtasks: %v", step, err)
+			}
+			sorted, _, err := repo.GetByUserID(user.ID, domain.TodoFilter{ParentID: parent.ID, Sort: domain.SortManual})
+			if err != nil {
+				t.Fatalf("%s: get by user: %v", step, err)
+			}
+			for name, todos := range map[string][]domain.Todo{"subtasks": listed, "sort=manual": sorted} {
+				got := make([]uint, len(todos))
+				for i, todo := range todos {
+					got[i] = todo.ID
+				}
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Fatalf("%s: %s lists %v, want %v", step, name, got, want)
+				}
+			}
+		}
+
+		check("create", []uint{id(0), id(1), id(2), id(3)})
+
+		if err := repo.Move(&subtasks[3], id(0), false); err != nil {
+			t.Fatalf("move: %v", err)
+		}
+		check("move", []uint{id(3), id(0), id(1), id(2)})
+
+		if err := repo.ReorderSubtasks(parent.ID, []uint{id(1), id(2), id(3), id(0)}); err != nil {
+			t.Fatalf("reorder: %v", err)
+		}
+		check("reorder", []uint{id(1), id(2), id(3), id(0)})
+
+		moved, err := repo.GetByID(id(1), user.ID)
+		if err != nil {
+			t.Fatalf("get subtask: %v", err)
+		}
+		if err := repo.Move(moved, id(0), true); err != nil {
+			t.Fatalf("move after reorder: %v", err)
+		}
+		check("move after reorder", []uint{id(2), id(3), id(0), id(1)})
+
+		if err := repo.Move(moved, id(2), false); !errors.Is(err, ErrStaleVersion) {
+			t.Fatalf("move with a stale version: got %v, want %v", err, ErrStaleVersion)
+		}
+	})
+}
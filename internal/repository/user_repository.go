@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"strings"
+
 	"github.com/iskhakmuhamad/todo-api/internal/domain"
 
 	"gorm.io/gorm"
@@ -9,7 +11,12 @@ import (
 type UserRepository interface {
 	Create(user *domain.User) error
 	GetByEmail(email string) (*domain.User, error)
+	GetByUsername(username string) (*domain.User, error)
 	GetByID(id uint) (*domain.User, error)
+	Update(user *domain.User, columns ...string) error
+	UseTOTPStep(id uint, step int64) (bool, error)
+	Search(filter domain.UserFilter) ([]domain.User, int64, error)
+	DeleteAccount(id uint) error
 }
 
 type userRepository struct {
@@ -24,9 +31,19 @@ func (r *userRepository) Create(user *domain.User) error {
 	return r.db.Create(user).Error
 }
 
+// GetByEmail finds the user by email, ignoring case.
 func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
 	var user domain.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.db.Where("LOWER(email) = ?", strings.ToLower(email)).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) GetByUsername(username string) (*domain.User, error) {
+	var user domain.User
+	err := r.db.Where("username = ?", username).First(&user).Error
 	if err != nil {
 		return nil, err
 	}
@@ -41,3 +58,87 @@ func (r *userRepository) GetByID(id uint) (*domain.User, error) {
 	}
 	return &user, nil
 }
+
+// Update writes the given columns of the user, and updated_at. Other columns
+// are left as they are in the database, so concurrent changes to them are not
+// overwritten with what the user looked like when it was loaded.
+func (r *userRepository) Update(user *domain.User, columns ...string) error {
+	return r.db.Model(user).Select(columns).Updates(user).Error
+}
+
+// UseTOTPStep records step as the user's last used TOTP time step, unless it
+// is not later than the one recorded. It reports false in that case, which
+// means the code was already used.
+func (r *userRepository) UseTOTPStep(id uint, step int64) (bool, error) {
+	result := r.db.Model(&domain.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *userRepository) Search(filter domain.UserFilter) ([]domain.User, int64, error) {
+	var users []domain.User
+	var total int64
+
+	query := r.db.Model(&domain.User{})
+
+	// Apply filters
+	if filter.Query != "" {
+		query = query.Where("email ILIKE ? OR username ILIKE ?", "%"+filter.Query+"%", "%"+filter.Query+"%")
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	switch filter.Status {
+	case "disabled":
+		query = query.Where("disabled_at IS NOT NULL")
+	case "active":
+		query = query.Where("disabled_at IS NULL")
+	}
+
+	// Count total
+	query.Count(&total)
+
+	// Apply pagination
+	if filter.Page > 0 && filter.Limit > 0 {
+		offset := (filter.Page - 1) * filter.Limit
+		query = query.Offset(offset).Limit(filter.Limit)
+	}
+
+	err := query.Order("id ASC").Find(&users).Error
+	return users, total, err
+}
+
+// DeleteAccount permanently removes the user together with everything they
+// own, in a single transaction.
+func (r *userRepository) DeleteAccount(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		sessionIDs := tx.Model(&domain.Session{}).Select("id").Where("user_id = ?", id)
+		if err := tx.Where("session_id IN (?)", sessionIDs).Delete(&domain.RefreshToken{}).Error; err != nil {
+			return err
+		}
+
+		owned := []interface{}{
+			&domain.Todo{},
+			&domain.Category{},
+			&domain.Tag{},
+			&domain.Session{},
+			&domain.UserToken{},
+			&domain.RecoveryCode{},
+			&domain.PersonalAccessToken{},
+			&domain.ExternalIdentity{},
+			&domain.ExportJob{},
+			&domain.IdempotencyKey{},
+		}
+		for _, model := range owned {
+			if err := tx.Unscoped().Where("user_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Unscoped().Delete(&domain.User{}, id).Error
+	})
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
 	"github.com/iskhakmuhamad/todo-api/internal/handler"
 	"github.com/iskhakmuhamad/todo-api/internal/middleware"
 
@@ -12,7 +13,17 @@ func SetupRoutes(
 	authHandler *handler.AuthHandler,
 	todoHandler *handler.TodoHandler,
 	categoryHandler *handler.CategoryHandler,
+	mfaHandler *handler.MFAHandler,
+	accessTokenHandler *handler.AccessTokenHandler,
+	adminHandler *handler.AdminHandler,
+	oidcHandler *handler.OIDCHandler,
+	jwksHandler *handler.JWKSHandler,
+	accountHandler *handler.AccountHandler,
+	exportHandler *handler.ExportHandler,
+	trashHandler *handler.TrashHandler,
+	tagHandler *handler.TagHandler,
 	authMiddleware *middleware.AuthMiddleware,
+	idempotency *middleware.IdempotencyMiddleware,
 ) {
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
@@ -22,31 +33,113 @@ func SetupRoutes(
 		})
 	})
 
+	// Public keys for verifying access tokens
+	app.Get("/.well-known/jwks.json", jwksHandler.GetKeys)
+
 	api := app.Group("/api/v1")
 
 	// Auth routes (public)
-	auth := api.Group("/auth")
+	auth := api.Group("/auth", idempotency.Refuse)
 	auth.Post("/register", authHandler.Register)
 	auth.Post("/login", authHandler.Login)
-	auth.Post("/logout", authHandler.Logout)
+	auth.Post("/login/mfa", authHandler.LoginMFA)
+	auth.Get("/oidc/login", oidcHandler.Login)
+	auth.Get("/oidc/callback", oidcHandler.Callback)
+	auth.Post("/refresh", authHandler.Refresh)
+	auth.Post("/logout", authMiddleware.ValidateJWT, authHandler.Logout)
+	auth.Post("/forgot-password", authHandler.ForgotPassword)
+	auth.Post("/reset-password", authHandler.ResetPassword)
+	auth.Post("/verify-email", authHandler.VerifyEmail)
+	auth.Post("/verify-email/resend", authHandler.ResendVerification)
+	auth.Post("/unlock", authHandler.UnlockAccount)
+
+	// Session routes (protected)
+	sessions := auth.Group("/sessions", authMiddleware.ValidateJWT)
+	sessions.Get("/", authHandler.GetSessions)
+	sessions.Post("/revoke-others", authHandler.RevokeOtherSessions)
+	sessions.Delete("/:id", authHandler.RevokeSession)
+
+	// Two-factor authentication routes (protected)
+	mfa := auth.Group("/mfa", authMiddleware.ValidateJWT)
+	mfa.Post("/enroll", mfaHandler.Enroll)
+	mfa.Post("/confirm", mfaHandler.Confirm)
+	mfa.Post("/disable", mfaHandler.Disable)
+	mfa.Post("/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+
+	// Current user routes (session only)
+	me := api.Group("/me", idempotency.Refuse, authMiddleware.ValidateJWT)
+	me.Get("/", accountHandler.GetProfile)
+	me.Patch("/", accountHandler.UpdateProfile)
+	me.Delete("/", accountHandler.DeleteAccount)
+	me.Post("/password", accountHandler.ChangePassword)
+	me.Post("/export", exportHandler.Create)
+	me.Get("/export", exportHandler.GetAll)
+	me.Get("/export/:id", exportHandler.GetByID)
+	me.Get("/export/:id/download", exportHandler.Download)
 
-	// Protected routes
-	protected := api.Group("", authMiddleware.ValidateJWT)
+	// Personal access token routes (session only)
+	tokens := api.Group("/tokens", idempotency.Refuse, authMiddleware.ValidateJWT)
+	tokens.Post("/", accessTokenHandler.Create)
+	tokens.Get("/", accessTokenHandler.GetAll)
+	tokens.Delete("/:id", accessTokenHandler.Revoke)
+
+	// Admin routes (session only)
+	admin := api.Group("/admin", idempotency.Refuse, authMiddleware.ValidateJWT, authMiddleware.RequireRole(domain.RoleAdmin))
+	admin.Get("/users", adminHandler.GetUsers)
+	admin.Get("/users/:id", adminHandler.GetUser)
+	admin.Post("/users/:id/disable", adminHandler.DisableUser)
+	admin.Post("/users/:id/enable", adminHandler.EnableUser)
+	admin.Post("/users/:id/force-password-reset", adminHandler.ForcePasswordReset)
+	admin.Post("/users/:id/unlock", adminHandler.UnlockUser)
+	admin.Put("/users/:id/role", adminHandler.UpdateRole)
+
+	// Protected routes (session JWT or personal access token). Idempotency keys
+	// are only honoured here, after the scope check: the account routes above
+	// return secrets that must not be stored and replayed, so they refuse them.
+	protected := api.Group("", authMiddleware.Authenticate, authMiddleware.RequireVerifiedEmail)
 
 	// Category routes
-	categories := protected.Group("/categories")
+	categories := protected.Group("/categories", authMiddleware.RequireScope("categories"), idempotency.Handle)
 	categories.Post("/", categoryHandler.Create)
 	categories.Get("/", categoryHandler.GetAll)
+	categories.Get("/trash", trashHandler.GetCategories)
+	categories.Post("/trash/:id/restore", trashHandler.RestoreCategory)
+	categories.Delete("/trash/:id", trashHandler.PurgeCategory)
 	categories.Get("/:id", categoryHandler.GetByID)
-	categories.Put("/:id", categoryHandler.Update)
+	categories.Put("/:id", categoryHandler.Replace)
+	categories.Patch("/:id", categoryHandler.Patch)
 	categories.Delete("/:id", categoryHandler.Delete)
 
+	// Tag routes
+	tags := protected.Group("/tags", authMiddleware.RequireScope("todos"), idempotency.Handle)
+	tags.Post("/", tagHandler.Create)
+	tags.Get("/", tagHandler.GetAll)
+	tags.Get("/:id", tagHandler.GetByID)
+	tags.Put("/:id", tagHandler.Replace)
+	tags.Patch("/:id", tagHandler.Patch)
+	tags.Delete("/:id", tagHandler.Delete)
+	tags.Post("/:id/merge", tagHandler.Merge)
+
 	// Todo routes
-	todos := protected.Group("/todos")
+	todos := protected.Group("/todos", authMiddleware.RequireScope("todos"), idempotency.Handle)
 	todos.Post("/", todoHandler.Create)
+	todos.Post("/bulk", todoHandler.Bulk)
 	todos.Get("/", todoHandler.GetAll)
+	todos.Get("/next", todoHandler.Next)
+	todos.Get("/trash", trashHandler.GetTodos)
+	todos.Post("/trash/:id/restore", trashHandler.RestoreTodo)
+	todos.Delete("/trash/:id", trashHandler.PurgeTodo)
 	todos.Get("/:id", todoHandler.GetByID)
-	todos.Put("/:id", todoHandler.Update)
+	todos.Put("/:id", todoHandler.Replace)
+	todos.Patch("/:id", todoHandler.Patch)
 	todos.Delete("/:id", todoHandler.Delete)
 	todos.Patch("/:id/toggle", todoHandler.ToggleStatus)
+	todos.Post("/:id/skip", todoHandler.Skip)
+	todos.Post("/:id/move", todoHandler.Move)
+	todos.Get("/:id/subtasks", todoHandler.GetSubtasks)
+	todos.Post("/:id/subtasks", todoHandler.CreateSubtask)
+	todos.Put("/:id/subtasks/order", todoHandler.ReorderSubtasks)
+	todos.Get("/:id/dependencies", todoHandler.GetDependencies)
+	todos.Post("/:id/dependencies", todoHandler.AddDependency)
+	todos.Delete("/:id/dependencies/:blockerId", todoHandler.RemoveDependency)
 }
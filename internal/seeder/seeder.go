@@ -54,16 +54,19 @@ func seedUsers(db *gorm.DB) error {
 			Email:    "john@example.com",
 			Username: "john_doe",
 			Password: string(hashedPassword),
+			Role:     domain.RoleUser,
 		},
 		{
 			Email:    "jane@example.com",
 			Username: "jane_smith",
 			Password: string(hashedPassword),
+			Role:     domain.RoleUser,
 		},
 		{
 			Email:    "admin@example.com",
 			Username: "admin",
 			Password: string(hashedPassword),
+			Role:     domain.RoleAdmin,
 		},
 	}
 
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/repository"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
+)
+
+type AccessTokenService interface {
+	Create(userID uint, req domain.CreateAccessTokenRequest) (*domain.CreateAccessTokenResponse, error)
+	GetAll(userID uint) ([]domain.PersonalAccessToken, error)
+	Revoke(id, userID uint) error
+}
+
+type accessTokenService struct {
+	accessTokenRepo repository.AccessTokenRepository
+}
+
+func NewAccessTokenService(accessTokenRepo repository.AccessTokenRepository) AccessTokenService {
+	return &accessTokenService{accessTokenRepo: accessTokenRepo}
+}
+
+func (s *accessTokenService) Create(userID uint, req domain.CreateAccessTokenRequest) (*domain.CreateAccessTokenResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, utils.NewFieldError("name", "required", "name is required")
+	}
+
+	if len(req.Scopes) == 0 {
+		return nil, utils.NewFieldError("scopes", "required", "at least one scope is required")
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(domain.AccessTokenScopes, scope) {
+			return nil, utils.NewFieldError("scopes", "oneof", fmt.Sprintf("unknown scope %q", scope))
+		}
+	}
+
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return nil, utils.NewFieldError("expires_at", "future", "expires_at must be in the future")
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	plain := domain.AccessTokenPrefix + secret
+
+	token := &domain.PersonalAccessToken{
+		UserID:      userID,
+		Name:        name,
+		TokenPrefix: plain[:len(domain.AccessTokenPrefix)+6],
+		TokenHash:   utils.HashToken(plain),
+		Scopes:      slices.Compact(slices.Sorted(slices.Values(req.Scopes))),
+		ExpiresAt:   req.ExpiresAt,
+	}
+
+	if err := s.accessTokenRepo.Create(token); err != nil {
+		return nil, err
+	}
+
+	return &domain.CreateAccessTokenResponse{
+		Token:       plain,
+		AccessToken: *token,
+	}, nil
+}
+
+func (s *accessTokenService) GetAll(userID uint) ([]domain.PersonalAccessToken, error) {
+	return s.accessTokenRepo.GetByUserID(userID)
+}
+
+func (s *accessTokenService) Revoke(id, userID uint) error {
+	revoked, err := s.accessTokenRepo.Revoke(id, userID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAccessTokenNotFound
+	}
+	return nil
+}
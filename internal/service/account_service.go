@@ -0,0 +1,147 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/repository"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type AccountService interface {
+	GetProfile(userID uint) (*domain.User, error)
+	UpdateProfile(userID uint, req domain.UpdateProfileRequest) (*domain.User, error)
+	ChangePassword(userID, sessionID uint, req domain.ChangePasswordRequest) error
+	DeleteAccount(userID uint, req domain.DeleteAccountRequest) error
+}
+
+type accountService struct {
+	userRepo      repository.UserRepository
+	authService   AuthService
+	exportService ExportService
+}
+
+func NewAccountService(userRepo repository.UserRepository, authService AuthService, exportService ExportService) AccountService {
+	return &accountService{
+		userRepo:      userRepo,
+		authService:   authService,
+		exportService: exportService,
+	}
+}
+
+func (s *accountService) GetProfile(userID uint) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	return user, nil
+}
+
+// UpdateProfile changes the username and/or email. A new email address has
+// to be verified again.
+func (s *accountService) UpdateProfile(userID uint, req domain.UpdateProfileRequest) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []string
+	if req.Username != nil && *req.Username != user.Username {
+		username := strings.TrimSpace(*req.Username)
+		if len(username) < 3 {
+			return nil, utils.NewFieldError("username", "min", "username must be at least 3 characters")
+		}
+		if existing, err := s.userRepo.GetByUsername(username); err == nil && existing.ID != user.ID {
+			return nil, ErrUsernameTaken
+		} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		user.Username = username
+		columns = append(columns, "username")
+	}
+
+	emailChanged := false
+	if req.Email != nil && !strings.EqualFold(*req.Email, user.Email) {
+		email := strings.TrimSpace(*req.Email)
+		if email == "" {
+			return nil, utils.NewFieldError("email", "required", "email is required")
+		}
+		if existing, err := s.userRepo.GetByEmail(email); err == nil && existing.ID != user.ID {
+			return nil, ErrEmailTaken
+		} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		user.Email = email
+		user.EmailVerifiedAt = nil
+		emailChanged = true
+		columns = append(columns, "email", "email_verified_at")
+	}
+
+	if len(columns) == 0 {
+		return user, nil
+	}
+	if err := s.userRepo.Update(user, columns...); err != nil {
+		return nil, err
+	}
+
+	if emailChanged {
+		if err := s.authService.SendVerificationEmail(user); err != nil {
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// ChangePassword sets a new password, signs out every other session and
+// revokes the user's personal access tokens.
+func (s *accountService) ChangePassword(userID, sessionID uint, req domain.ChangePasswordRequest) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	if len(req.NewPassword) < 6 {
+		return utils.NewFieldError("new_password", "min", "new_password must be at least 6 characters")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user.Password = string(hashedPassword)
+	user.MustResetPassword = false
+	if err := s.userRepo.Update(user, "password", "must_reset_password"); err != nil {
+		return err
+	}
+
+	return s.authService.RevokeCredentials(user.ID, sessionID)
+}
+
+// DeleteAccount permanently deletes the user and all of their todos,
+// categories, credentials and data export archives.
+func (s *accountService) DeleteAccount(userID uint, req domain.DeleteAccountRequest) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	if err := s.exportService.RemoveFiles(user.ID); err != nil {
+		return err
+	}
+
+	return s.userRepo.DeleteAccount(user.ID)
+}
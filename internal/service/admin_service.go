@@ -0,0 +1,195 @@
+package service
+
+import (
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/apperror"
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/loginguard"
+	"github.com/iskhakmuhamad/todo-api/internal/repository"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
+)
+
+var (
+	errCannotDisableSelf = apperror.Forbidden("cannot_disable_self", "you cannot disable your own account")
+	errCannotDemoteSelf  = apperror.Forbidden("cannot_demote_self", "you cannot remove your own admin role")
+)
+
+type AdminService interface {
+	ListUsers(filter domain.UserFilter) ([]domain.AdminUser, int64, error)
+	GetUser(id uint) (*domain.AdminUser, error)
+	DisableUser(adminID, id uint) (*domain.User, error)
+	EnableUser(id uint) (*domain.User, error)
+	ForcePasswordReset(id uint) (*domain.User, error)
+	UpdateRole(adminID, id uint, req domain.UpdateRoleRequest) (*domain.User, error)
+	UnlockUser(id uint) (*domain.User, error)
+}
+
+type adminService struct {
+	userRepo    repository.UserRepository
+	todoRepo    repository.TodoRepository
+	sessionRepo repository.SessionRepository
+	authService AuthService
+	loginGuard  *loginguard.Guard
+}
+
+func NewAdminService(
+	userRepo repository.UserRepository,
+	todoRepo repository.TodoRepository,
+	sessionRepo repository.SessionRepository,
+	authService AuthService,
+	loginGuard *loginguard.Guard,
+) AdminService {
+	return &adminService{
+		userRepo:    userRepo,
+		todoRepo:    todoRepo,
+		sessionRepo: sessionRepo,
+		authService: authService,
+		loginGuard:  loginGuard,
+	}
+}
+
+func (s *adminService) ListUsers(filter domain.UserFilter) ([]domain.AdminUser, int64, error) {
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+
+	users, total, err := s.userRepo.Search(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids := make([]uint, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+
+	counts, err := s.todoRepo.CountByUserIDs(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]domain.AdminUser, len(users))
+	for i, user := range users {
+		result[i] = domain.AdminUser{User: user, TodoCounts: counts[user.ID]}
+	}
+
+	return result, total, nil
+}
+
+func (s *adminService) GetUser(id uint) (*domain.AdminUser, error) {
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	counts, err := s.todoRepo.CountByUserIDs([]uint{id})
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.AdminUser{User: *user, TodoCounts: counts[id]}, nil
+}
+
+// DisableUser blocks the account and signs it out everywhere.
+func (s *adminService) DisableUser(adminID, id uint) (*domain.User, error) {
+	if adminID == id {
+		return nil, errCannotDisableSelf
+	}
+
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	if user.DisabledAt == nil {
+		now := time.Now()
+		user.DisabledAt = &now
+		if err := s.userRepo.Update(user, "disabled_at"); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := s.sessionRepo.RevokeAllExcept(user.ID, 0); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *adminService) EnableUser(id uint) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	user.DisabledAt = nil
+	if err := s.userRepo.Update(user, "disabled_at"); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ForcePasswordReset signs the user out, revokes their personal access
+// tokens, refuses further logins until the password is changed and emails
+// them a reset link.
+func (s *adminService) ForcePasswordReset(id uint) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	user.MustResetPassword = true
+	if err := s.userRepo.Update(user, "must_reset_password"); err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.RevokeCredentials(user.ID, 0); err != nil {
+		return nil, err
+	}
+
+	if err := s.authService.ForgotPassword(domain.ForgotPasswordRequest{Email: user.Email}); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *adminService) UpdateRole(adminID, id uint, req domain.UpdateRoleRequest) (*domain.User, error) {
+	if req.Role != domain.RoleUser && req.Role != domain.RoleAdmin {
+		return nil, utils.NewFieldError("role", "oneof", "role must be one of: user, admin")
+	}
+	if adminID == id && req.Role != domain.RoleAdmin {
+		return nil, errCannotDemoteSelf
+	}
+
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	user.Role = req.Role
+	if err := s.userRepo.Update(user, "role"); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// UnlockUser clears failed login attempts that locked the account.
+func (s *adminService) UnlockUser(id uint) (*domain.User, error) {
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	if err := s.loginGuard.Unlock(user.Email); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
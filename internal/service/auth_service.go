@@ -2,8 +2,15 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"log"
+	"time"
 
+	"github.com/iskhakmuhamad/todo-api/internal/apperror"
+	"github.com/iskhakmuhamad/todo-api/internal/config"
 	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/loginguard"
+	"github.com/iskhakmuhamad/todo-api/internal/mailer"
 	"github.com/iskhakmuhamad/todo-api/internal/repository"
 	"github.com/iskhakmuhamad/todo-api/pkg/utils"
 
@@ -11,28 +18,96 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	errInvalidCredentials  = apperror.Unauthorized("invalid_credentials", "invalid credentials")
+	errInvalidMFAToken     = apperror.Unauthorized("invalid_mfa_token", "invalid or expired mfa token")
+	errInvalidRefreshToken = apperror.Unauthorized("invalid_refresh_token", "invalid refresh token")
+	errRefreshTokenReused  = apperror.Unauthorized("refresh_token_reused", "refresh token reuse detected, session revoked")
+	errInvalidUserToken    = apperror.BadRequest("invalid_token", "invalid or expired token")
+
+	ErrEmailTaken            = apperror.Conflict("email_taken", "email is already registered")
+	ErrUsernameTaken         = apperror.Conflict("username_taken", "username is already taken")
+	ErrAccountDisabled       = apperror.Forbidden("account_disabled", "account is disabled")
+	ErrPasswordResetRequired = apperror.Forbidden("password_reset_required", "password reset required, check your email for a reset link")
+	ErrEmailNotVerified      = apperror.Forbidden("email_not_verified", "email address is not verified")
+	ErrSessionNotFound       = apperror.NotFound("session_not_found", "session not found")
+)
+
+const unlockTokenTTL = time.Hour
+
+// dummyPasswordHash is compared against when the email is unknown, so a
+// failed login takes as long whether or not the account exists.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+
 type AuthService interface {
 	Register(req domain.RegisterRequest) (*domain.User, error)
-	Login(req domain.LoginRequest) (*domain.LoginResponse, error)
+	Login(req domain.LoginRequest, client domain.ClientInfo) (*domain.LoginResponse, error)
+	LoginMFA(req domain.MFALoginRequest, client domain.ClientInfo) (*domain.LoginResponse, error)
+	LoginWithIdentity(user *domain.User, deviceName string, client domain.ClientInfo) (*domain.LoginResponse, error)
+	Refresh(req domain.RefreshRequest, client domain.ClientInfo) (*domain.LoginResponse, error)
+	Logout(sessionID uint) error
+	ListSessions(userID, currentSessionID uint) ([]domain.Session, error)
+	RevokeSession(id, userID uint) error
+	RevokeOtherSessions(userID, currentSessionID uint) (int64, error)
+	ForgotPassword(req domain.ForgotPasswordRequest) error
+	ResetPassword(req domain.ResetPasswordRequest) error
+	VerifyEmail(req domain.VerifyEmailRequest) error
+	ResendVerification(req domain.ResendVerificationRequest, client domain.ClientInfo) error
+	UnlockAccount(req domain.UnlockAccountRequest) error
+	SendVerificationEmail(user *domain.User) error
+	RevokeCredentials(userID, keepSessionID uint) error
 }
 
 type authService struct {
-	userRepo  repository.UserRepository
-	jwtSecret string
+	userRepo        repository.UserRepository
+	sessionRepo     repository.SessionRepository
+	userTokenRepo   repository.UserTokenRepository
+	accessTokenRepo repository.AccessTokenRepository
+	mfaService      MFAService
+	loginGuard      *loginguard.Guard
+	resendGuard     *loginguard.Throttle
+	mailer          mailer.Mailer
+	jwtKeys         *utils.KeySet
+	cfg             *config.Config
 }
 
-func NewAuthService(userRepo repository.UserRepository, jwtSecret string) AuthService {
+func NewAuthService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	userTokenRepo repository.UserTokenRepository,
+	accessTokenRepo repository.AccessTokenRepository,
+	mfaService MFAService,
+	loginGuard *loginguard.Guard,
+	resendGuard *loginguard.Throttle,
+	mail mailer.Mailer,
+	jwtKeys *utils.KeySet,
+	cfg *config.Config,
+) AuthService {
 	return &authService{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
+		userRepo:        userRepo,
+		sessionRepo:     sessionRepo,
+		userTokenRepo:   userTokenRepo,
+		accessTokenRepo: accessTokenRepo,
+		mfaService:      mfaService,
+		loginGuard:      loginGuard,
+		resendGuard:     resendGuard,
+		mailer:          mail,
+		jwtKeys:         jwtKeys,
+		cfg:             cfg,
 	}
 }
 
 func (s *authService) Register(req domain.RegisterRequest) (*domain.User, error) {
 	// Check if user already exists
-	existingUser, _ := s.userRepo.GetByEmail(req.Email)
-	if existingUser != nil {
-		return nil, errors.New("user already exists")
+	if _, err := s.userRepo.GetByEmail(req.Email); err == nil {
+		return nil, ErrEmailTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if _, err := s.userRepo.GetByUsername(req.Username); err == nil {
+		return nil, ErrUsernameTaken
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
 	}
 
 	// Hash password
@@ -45,35 +120,552 @@ func (s *authService) Register(req domain.RegisterRequest) (*domain.User, error)
 		Email:    req.Email,
 		Username: req.Username,
 		Password: string(hashedPassword),
+		Role:     domain.RoleUser,
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
 		return nil, err
 	}
 
+	if err := s.SendVerificationEmail(user); err != nil {
+		return nil, err
+	}
+
 	return user, nil
 }
 
-func (s *authService) Login(req domain.LoginRequest) (*domain.LoginResponse, error) {
+func (s *authService) Login(req domain.LoginRequest, client domain.ClientInfo) (*domain.LoginResponse, error) {
+	failures, err := s.loginGuard.Begin(req.Email, client.IPAddress)
+	if err != nil {
+		return nil, guardError(err)
+	}
+
 	user, err := s.userRepo.GetByEmail(req.Email)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("invalid credentials")
+			_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+			if err := s.loginFailed(failures, nil); err != nil {
+				return nil, err
+			}
+			return nil, errInvalidCredentials
 		}
-		return nil, err
+		return nil, s.releaseAttempt(req.Email, client.IPAddress, err)
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, errors.New("invalid credentials")
+		if err := s.loginFailed(failures, user); err != nil {
+			return nil, err
+		}
+		return nil, errInvalidCredentials
+	}
+
+	// From here on the password was right, so the attempt is given back
+	if err := s.checkCanLogin(user); err != nil {
+		return nil, s.releaseAttempt(req.Email, client.IPAddress, err)
+	}
+
+	// With two-factor enabled the password only earns a short-lived token
+	// that has to be exchanged together with a TOTP code
+	if user.TOTPEnabledAt != nil {
+		if err := s.loginGuard.Release(req.Email, client.IPAddress); err != nil {
+			return nil, err
+		}
+		return s.mfaChallenge(user)
+	}
+
+	if err := s.loginGuard.Succeed(req.Email, client.IPAddress); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, req.DeviceName, client)
+}
+
+func (s *authService) LoginMFA(req domain.MFALoginRequest, client domain.ClientInfo) (*domain.LoginResponse, error) {
+	claims, err := utils.ValidateMFAToken(req.MFAToken, s.jwtKeys)
+	if err != nil {
+		return nil, errInvalidMFAToken
+	}
+
+	user, err := s.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidMFAToken
+		}
+		return nil, err
+	}
+
+	if user.TOTPEnabledAt == nil {
+		return nil, ErrMFANotEnabled
+	}
+
+	// Code guesses count against the same limits as password guesses
+	failures, err := s.loginGuard.Begin(user.Email, client.IPAddress)
+	if err != nil {
+		return nil, guardError(err)
+	}
+
+	if err := s.mfaService.VerifyCode(user, req.Code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.loginFailed(failures, user); err != nil {
+				return nil, err
+			}
+			return nil, err
+		}
+		return nil, s.releaseAttempt(user.Email, client.IPAddress, err)
+	}
+
+	if err := s.checkCanLogin(user); err != nil {
+		return nil, s.releaseAttempt(user.Email, client.IPAddress, err)
+	}
+
+	if err := s.loginGuard.Succeed(user.Email, client.IPAddress); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, req.DeviceName, client)
+}
+
+// LoginWithIdentity starts a session for a user that was already
+// authenticated by an external identity provider. Password based checks do
+// not apply, the provider is trusted for those, but a forced password reset
+// and the user's own two-factor authentication do.
+func (s *authService) LoginWithIdentity(user *domain.User, deviceName string, client domain.ClientInfo) (*domain.LoginResponse, error) {
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+	if user.MustResetPassword {
+		return nil, ErrPasswordResetRequired
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return s.mfaChallenge(user)
+	}
+
+	return s.startSession(user, deviceName, client)
+}
+
+// mfaChallenge answers a successful first factor for a user with two-factor
+// authentication enabled: a short-lived token that has to be exchanged at
+// LoginMFA together with a code.
+func (s *authService) mfaChallenge(user *domain.User) (*domain.LoginResponse, error) {
+	mfaTTL := time.Duration(s.cfg.MFATokenExpireMinutes) * time.Minute
+	mfaToken, err := utils.GenerateMFAToken(user.ID, user.Email, s.jwtKeys, mfaTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.LoginResponse{
+		MFARequired: true,
+		MFAToken:    mfaToken,
+	}, nil
+}
+
+func (s *authService) Refresh(req domain.RefreshRequest, client domain.ClientInfo) (*domain.LoginResponse, error) {
+	token, err := s.sessionRepo.GetRefreshTokenByHash(utils.HashToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	session, err := s.sessionRepo.GetByID(token.SessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidRefreshToken
+		}
+		return nil, err
+	}
+	if session.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, errInvalidRefreshToken
+	}
+
+	// A refresh token can only be exchanged once. Seeing it a second time means
+	// it was copied somewhere, so the whole session is killed.
+	fresh, err := s.sessionRepo.MarkRefreshTokenUsed(token.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		if err := s.sessionRepo.Revoke(session.ID); err != nil {
+			return nil, err
+		}
+		return nil, errRefreshTokenReused
+	}
+
+	user, err := s.userRepo.GetByID(session.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+
+	session.UserAgent = client.UserAgent
+	session.IPAddress = client.IPAddress
+	session.LastSeenAt = time.Now()
+	session.ExpiresAt = time.Now().Add(s.refreshTokenTTL())
+	renewed, err := s.sessionRepo.Renew(session)
+	if err != nil {
+		return nil, err
+	}
+	if !renewed {
+		// Revoked by a logout or reuse detection since it was read
+		return nil, errInvalidRefreshToken
+	}
+
+	return s.issueTokens(user, session)
+}
+
+func (s *authService) Logout(sessionID uint) error {
+	return s.sessionRepo.Revoke(sessionID)
+}
+
+func (s *authService) ListSessions(userID, currentSessionID uint) ([]domain.Session, error) {
+	sessions, err := s.sessionRepo.GetActiveByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	return sessions, nil
+}
+
+func (s *authService) RevokeSession(id, userID uint) error {
+	revoked, err := s.sessionRepo.RevokeForUser(id, userID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (s *authService) RevokeOtherSessions(userID, currentSessionID uint) (int64, error) {
+	return s.sessionRepo.RevokeAllExcept(userID, currentSessionID)
+}
+
+// ForgotPassword emails a reset link when the address belongs to an account.
+// It never reports whether the account exists.
+func (s *authService) ForgotPassword(req domain.ForgotPasswordRequest) error {
+	user, err := s.userRepo.GetByEmail(req.Email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	ttl := time.Duration(s.cfg.PasswordResetExpireMinutes) * time.Minute
+	token, err := s.createUserToken(user.ID, domain.TokenPurposePasswordReset, ttl)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %d minutes.\n\n%s/reset-password?token=%s\n\nIf you did not ask for this, you can ignore this email.",
+			user.Username, s.cfg.PasswordResetExpireMinutes, s.cfg.AppURL, token),
+	})
+
+	return nil
+}
+
+func (s *authService) ResetPassword(req domain.ResetPasswordRequest) error {
+	token, err := s.consumeUserToken(req.Token, domain.TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(token.UserID)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user.Password = string(hashedPassword)
+	user.MustResetPassword = false
+	if err := s.userRepo.Update(user, "password", "must_reset_password"); err != nil {
+		return err
+	}
+
+	// Whoever knew the old password should not stay signed in
+	return s.RevokeCredentials(user.ID, 0)
+}
+
+// RevokeCredentials signs the user out of every session but keepSessionID
+// (0 for all of them) and revokes their personal access tokens, which were
+// created with the old password.
+func (s *authService) RevokeCredentials(userID, keepSessionID uint) error {
+	if _, err := s.sessionRepo.RevokeAllExcept(userID, keepSessionID); err != nil {
+		return err
+	}
+	_, err := s.accessTokenRepo.RevokeAllForUser(userID)
+	return err
+}
+
+func (s *authService) VerifyEmail(req domain.VerifyEmailRequest) error {
+	token, err := s.consumeUserToken(req.Token, domain.TokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(token.UserID)
+	if err != nil {
+		return err
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	return s.userRepo.Update(user, "email_verified_at")
+}
+
+// ResendVerification emails a new verification link when the address
+// belongs to an unverified account. Requests are throttled per address and
+// IP whether or not the account exists, so neither the response nor the
+// throttling reveals which addresses are registered.
+func (s *authService) ResendVerification(req domain.ResendVerificationRequest, client domain.ClientInfo) error {
+	if err := s.resendGuard.Allow(req.Email, client.IPAddress); err != nil {
+		var locked *loginguard.LockedError
+		if errors.As(err, &locked) {
+			return apperror.TooManyRequests("verification_throttled",
+				"verification email was requested recently, please wait before requesting another",
+				locked.RetryAfter)
+		}
+		return err
+	}
+
+	user, err := s.userRepo.GetByEmail(req.Email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	return s.SendVerificationEmail(user)
+}
+
+func (s *authService) SendVerificationEmail(user *domain.User) error {
+	ttl := time.Duration(s.cfg.EmailVerificationExpireHours) * time.Hour
+	token, err := s.createUserToken(user.ID, domain.TokenPurposeEmailVerification, ttl)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %d hours.\n\n%s/verify-email?token=%s",
+			user.Username, s.cfg.EmailVerificationExpireHours, s.cfg.AppURL, token),
+	})
+
+	return nil
+}
+
+// createUserToken invalidates older tokens of the same purpose and stores a
+// new one, returning the plain token to send to the user.
+func (s *authService) createUserToken(userID uint, purpose domain.TokenPurpose, ttl time.Duration) (string, error) {
+	if err := s.userTokenRepo.InvalidateForUser(userID, purpose); err != nil {
+		return "", err
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.userTokenRepo.Create(&domain.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	}); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeUserToken validates a plain token and marks it as used.
+func (s *authService) consumeUserToken(plain string, purpose domain.TokenPurpose) (*domain.UserToken, error) {
+	if plain == "" {
+		return nil, errInvalidUserToken
+	}
+
+	token, err := s.userTokenRepo.GetByHash(utils.HashToken(plain), purpose)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidUserToken
+		}
+		return nil, err
+	}
+
+	if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, errInvalidUserToken
 	}
 
-	token, err := utils.GenerateJWT(user.ID, user.Email, s.jwtSecret, 24)
+	used, err := s.userTokenRepo.MarkUsed(token.ID)
 	if err != nil {
 		return nil, err
 	}
+	if !used {
+		return nil, errInvalidUserToken
+	}
+
+	return token, nil
+}
+
+// guardError turns a login lockout into a 429 carrying the retry delay.
+func guardError(err error) error {
+	var locked *loginguard.LockedError
+	if errors.As(err, &locked) {
+		return apperror.TooManyRequests("too_many_attempts", locked.Error(), locked.RetryAfter).Wrap(err)
+	}
+	return err
+}
 
+// sendMail delivers in the background so response times do not depend on
+// the mail server (or reveal whether an email was sent at all).
+func (s *authService) sendMail(msg mailer.Message) {
+	go func() {
+		if err := s.mailer.Send(msg); err != nil {
+			log.Printf("Failed to send mail to %s: %v", msg.To, err)
+		}
+	}()
+}
+
+func (s *authService) UnlockAccount(req domain.UnlockAccountRequest) error {
+	token, err := s.consumeUserToken(req.Token, domain.TokenPurposeAccountUnlock)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetByID(token.UserID)
+	if err != nil {
+		return err
+	}
+
+	return s.loginGuard.Unlock(user.Email)
+}
+
+// loginFailed is called when the attempt reserved with failures turned out
+// to be a failure, which Begin already counted. When it locked a real
+// account, the owner is emailed a link to unlock it.
+func (s *authService) loginFailed(failures int, user *domain.User) error {
+	if s.loginGuard.LocksOut(failures) && user != nil {
+		token, err := s.createUserToken(user.ID, domain.TokenPurposeAccountUnlock, unlockTokenTTL)
+		if err != nil {
+			return err
+		}
+
+		s.sendMail(mailer.Message{
+			To:      user.Email,
+			Subject: "Your account has been locked",
+			Body: fmt.Sprintf("Hi %s,\n\nWe locked your account after several failed sign-in attempts. If this was you, unlock it with the link below. If not, consider resetting your password.\n\n%s/unlock-account?token=%s",
+				user.Username, s.cfg.AppURL, token),
+		})
+	}
+
+	return nil
+}
+
+// releaseAttempt gives back an attempt reserved with Begin that did not fail
+// on the credentials, and returns err.
+func (s *authService) releaseAttempt(email, ip string, err error) error {
+	if releaseErr := s.loginGuard.Release(email, ip); releaseErr != nil {
+		return releaseErr
+	}
+	return err
+}
+
+// checkCanLogin is run once the credentials are known to be valid, so its
+// errors do not reveal anything about accounts the caller cannot access.
+func (s *authService) checkCanLogin(user *domain.User) error {
+	if user.DisabledAt != nil {
+		return ErrAccountDisabled
+	}
+	if user.MustResetPassword {
+		return ErrPasswordResetRequired
+	}
+	if s.cfg.RequireEmailVerification == "login" && user.EmailVerifiedAt == nil {
+		return ErrEmailNotVerified
+	}
+	return nil
+}
+
+// startSession records a new login session and issues its first tokens.
+func (s *authService) startSession(user *domain.User, deviceName string, client domain.ClientInfo) (*domain.LoginResponse, error) {
+	if deviceName == "" {
+		deviceName = "Unknown device"
+	}
+
+	session := &domain.Session{
+		UserID:     user.ID,
+		DeviceName: deviceName,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		LastSeenAt: time.Now(),
+		ExpiresAt:  time.Now().Add(s.refreshTokenTTL()),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, session)
+}
+
+// issueTokens signs a new access token for the session and stores a fresh
+// refresh token alongside it.
+func (s *authService) issueTokens(user *domain.User, session *domain.Session) (*domain.LoginResponse, error) {
+	accessTTL := time.Duration(s.cfg.AccessTokenExpireMinutes) * time.Minute
+	accessToken, err := utils.GenerateJWT(user.ID, session.ID, user.Email, string(user.Role), s.jwtKeys, accessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sessionRepo.CreateRefreshToken(&domain.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: session.ExpiresAt,
+	}); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(accessTTL)
 	return &domain.LoginResponse{
-		Token: token,
-		User:  *user,
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    &expiresAt,
+		User:         user,
 	}, nil
 }
+
+func (s *authService) refreshTokenTTL() time.Duration {
+	return time.Duration(s.cfg.RefreshTokenExpireHours) * time.Hour
+}
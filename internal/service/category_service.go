@@ -1,16 +1,24 @@
 package service
 
 import (
+	"errors"
+
 	"github.com/iskhakmuhamad/todo-api/internal/domain"
 	"github.com/iskhakmuhamad/todo-api/internal/repository"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
+
+	"gorm.io/gorm"
 )
 
+const defaultCategoryColor = "#3B82F6"
+
 type CategoryService interface {
 	Create(userID uint, req domain.CreateCategoryRequest) (*domain.Category, error)
 	GetAll(userID uint) ([]domain.Category, error)
 	GetByID(id, userID uint) (*domain.Category, error)
-	Update(id, userID uint, req domain.UpdateCategoryRequest) (*domain.Category, error)
-	Delete(id, userID uint) error
+	Replace(id, userID, version uint, req domain.ReplaceCategoryRequest) (*domain.Category, error)
+	Patch(id, userID, version uint, patch domain.CategoryPatch) (*domain.Category, error)
+	Delete(id, userID, version uint, req domain.DeleteCategoryRequest) (*domain.DeleteCategoryResult, error)
 }
 
 type categoryService struct {
@@ -27,10 +35,11 @@ func (s *categoryService) Create(userID uint, req domain.CreateCategoryRequest)
 		Name:        req.Name,
 		Description: req.Description,
 		Color:       req.Color,
+		Version:     1,
 	}
 
 	if category.Color == "" {
-		category.Color = "#3B82F6"
+		category.Color = defaultCategoryColor
 	}
 
 	if err := s.categoryRepo.Create(category); err != nil {
@@ -45,32 +54,117 @@ func (s *categoryService) GetAll(userID uint) ([]domain.Category, error) {
 }
 
 func (s *categoryService) GetByID(id, userID uint) (*domain.Category, error) {
-	return s.categoryRepo.GetByID(id, userID)
+	category, err := s.categoryRepo.GetByID(id, userID)
+	if err != nil {
+		return nil, notFound(err, ErrCategoryNotFound)
+	}
+	return category, nil
 }
 
-func (s *categoryService) Update(id, userID uint, req domain.UpdateCategoryRequest) (*domain.Category, error) {
-	category, err := s.categoryRepo.GetByID(id, userID)
+// Replace overwrites every field of the category, as in a PUT. version is
+// the If-Match precondition, 0 when there is none.
+func (s *categoryService) Replace(id, userID, version uint, req domain.ReplaceCategoryRequest) (*domain.Category, error) {
+	category, err := s.getForUpdate(id, userID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.save(category, version, req)
+}
+
+// Patch applies a JSON merge patch and validates the resulting category.
+func (s *categoryService) Patch(id, userID, version uint, patch domain.CategoryPatch) (*domain.Category, error) {
+	category, err := s.getForUpdate(id, userID, version)
 	if err != nil {
 		return nil, err
 	}
 
-	if req.Name != "" {
-		category.Name = req.Name
+	req := domain.ReplaceCategoryRequest{
+		Name:        category.Name,
+		Description: category.Description,
+		Color:       category.Color,
 	}
-	if req.Description != "" {
-		category.Description = req.Description
+	patch.Apply(&req)
+	if err := utils.Validate(req); err != nil {
+		return nil, err
 	}
-	if req.Color != "" {
-		category.Color = req.Color
+
+	return s.save(category, version, req)
+}
+
+func (s *categoryService) save(category *domain.Category, version uint, req domain.ReplaceCategoryRequest) (*domain.Category, error) {
+	category.Name = req.Name
+	category.Description = req.Description
+	category.Color = req.Color
+	if category.Color == "" {
+		category.Color = defaultCategoryColor
 	}
 
 	if err := s.categoryRepo.Update(category); err != nil {
-		return nil, err
+		return nil, staleVersion(err, version)
 	}
 
 	return category, nil
 }
 
-func (s *categoryService) Delete(id, userID uint) error {
-	return s.categoryRepo.Delete(id, userID)
+// Delete moves the category to the trash. Its todos are detached by
+// default, or moved to req.TargetID, or deleted along with it.
+func (s *categoryService) Delete(id, userID, version uint, req domain.DeleteCategoryRequest) (*domain.DeleteCategoryResult, error) {
+	category, err := s.getForUpdate(id, userID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Strategy == "" {
+		req.Strategy = domain.DeleteStrategyDetach
+	}
+	if req.Strategy == domain.DeleteStrategyMove {
+		if err := s.checkTarget(category, req.TargetID); err != nil {
+			return nil, err
+		}
+	}
+
+	affected, err := s.categoryRepo.Delete(category, req.Strategy, req.TargetID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTargetNotFound) {
+			// Deleted since checkTarget looked at it
+			return nil, utils.NewFieldError("target_id", "exists", "target_id does not refer to one of your categories")
+		}
+		return nil, staleVersion(err, version)
+	}
+
+	return &domain.DeleteCategoryResult{
+		Strategy:      req.Strategy,
+		TodosAffected: affected,
+	}, nil
+}
+
+// checkTarget makes sure todos are only moved to another of the user's own
+// categories.
+func (s *categoryService) checkTarget(category *domain.Category, targetID *uint) error {
+	if targetID == nil {
+		return utils.NewFieldError("target_id", "required", "target_id is required for the move strategy")
+	}
+	if *targetID == category.ID {
+		return utils.NewFieldError("target_id", "nefield", "target_id must be a different category")
+	}
+
+	if _, err := s.categoryRepo.GetByID(*targetID, category.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.NewFieldError("target_id", "exists", "target_id does not refer to one of your categories")
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *categoryService) getForUpdate(id, userID, version uint) (*domain.Category, error) {
+	category, err := s.GetByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(category.Version, version); err != nil {
+		return nil, err
+	}
+	return category, nil
 }
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/iskhakmuhamad/todo-api/internal/apperror"
+	"github.com/iskhakmuhamad/todo-api/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserNotFound        = apperror.NotFound("user_not_found", "user not found")
+	ErrTodoNotFound        = apperror.NotFound("todo_not_found", "todo not found")
+	ErrCategoryNotFound    = apperror.NotFound("category_not_found", "category not found")
+	ErrTagNotFound         = apperror.NotFound("tag_not_found", "tag not found")
+	ErrDependencyNotFound  = apperror.NotFound("dependency_not_found", "dependency not found")
+	ErrAccessTokenNotFound = apperror.NotFound("access_token_not_found", "access token not found")
+	ErrExportNotFound      = apperror.NotFound("export_not_found", "export not found")
+
+	ErrIncorrectPassword = apperror.Forbidden("incorrect_password", "password is incorrect")
+
+	ErrPreconditionFailed = apperror.PreconditionFailed("precondition_failed", "resource has been modified, fetch it again before updating")
+	ErrEditConflict       = apperror.Conflict("edit_conflict", "resource was modified by another request, please retry")
+)
+
+// checkVersion enforces an If-Match precondition. A version of 0 means the
+// client did not send one.
+func checkVersion(current, expected uint) error {
+	if expected != 0 && current != expected {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// staleVersion reports a lost update race the same way as a failed
+// precondition when the client asked for one.
+func staleVersion(err error, expected uint) error {
+	if !errors.Is(err, repository.ErrStaleVersion) {
+		return err
+	}
+	if expected != 0 {
+		return ErrPreconditionFailed
+	}
+	return ErrEditConflict
+}
+
+// notFound replaces gorm.ErrRecordNotFound with the typed error for the
+// resource that was looked up. Other errors are returned unchanged.
+func notFound(err error, typed *apperror.Error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return typed
+	}
+	return err
+}
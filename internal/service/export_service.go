@@ -0,0 +1,360 @@
+package service
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/apperror"
+	"github.com/iskhakmuhamad/todo-api/internal/config"
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/repository"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+var ErrExportNotReady = apperror.Conflict("export_not_ready", "export is not ready for download")
+
+// exportSweepInterval is how often the worker picks up jobs that did not fit
+// in the queue and removes expired archives.
+const exportSweepInterval = time.Minute
+
+type ExportService interface {
+	Request(userID uint) (*domain.ExportJob, error)
+	GetAll(userID uint) ([]domain.ExportJob, error)
+	GetByID(id, userID uint) (*domain.ExportJob, error)
+	ArchivePath(id, userID uint) (string, error)
+	RemoveFiles(userID uint) error
+	Start()
+}
+
+type exportService struct {
+	exportRepo repository.ExportRepository
+	cfg        *config.Config
+	queue      chan uint
+}
+
+func NewExportService(exportRepo repository.ExportRepository, cfg *config.Config) ExportService {
+	return &exportService{
+		exportRepo: exportRepo,
+		cfg:        cfg,
+		queue:      make(chan uint, 64),
+	}
+}
+
+// Request queues a new export for the user. While an export is still pending
+// or running, that job is returned instead of starting another one.
+func (s *exportService) Request(userID uint) (*domain.ExportJob, error) {
+	active, err := s.exportRepo.GetActiveByUserID(userID)
+	if err == nil {
+		return active, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	job := &domain.ExportJob{
+		UserID: userID,
+		Status: domain.ExportStatusPending,
+	}
+	if err := s.exportRepo.Create(job); err != nil {
+		return nil, err
+	}
+
+	s.enqueue(job.ID)
+	return job, nil
+}
+
+func (s *exportService) GetAll(userID uint) ([]domain.ExportJob, error) {
+	return s.exportRepo.GetByUserID(userID)
+}
+
+func (s *exportService) GetByID(id, userID uint) (*domain.ExportJob, error) {
+	job, err := s.exportRepo.GetByID(id, userID)
+	if err != nil {
+		return nil, notFound(err, ErrExportNotFound)
+	}
+	return job, nil
+}
+
+// ArchivePath returns the location of a finished archive on disk.
+func (s *exportService) ArchivePath(id, userID uint) (string, error) {
+	job, err := s.GetByID(id, userID)
+	if err != nil {
+		return "", err
+	}
+	if job.Status != domain.ExportStatusCompleted || job.FilePath == "" {
+		return "", ErrExportNotReady
+	}
+	return job.FilePath, nil
+}
+
+// RemoveFiles deletes every archive built for the user, e.g. before the
+// account itself is deleted.
+func (s *exportService) RemoveFiles(userID uint) error {
+	jobs, err := s.exportRepo.GetByUserID(userID)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.FilePath == "" {
+			continue
+		}
+		if err := os.Remove(job.FilePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start runs the export worker in the background. Jobs interrupted by a
+// previous shutdown are queued again.
+func (s *exportService) Start() {
+	if err := s.exportRepo.ResetRunning(); err != nil {
+		log.Printf("export: failed to reset interrupted jobs: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(exportSweepInterval)
+		defer ticker.Stop()
+
+		s.sweep()
+		for {
+			select {
+			case id := <-s.queue:
+				s.run(id)
+			case <-ticker.C:
+				s.sweep()
+			}
+		}
+	}()
+}
+
+// enqueue hands a job to the worker without blocking the request. When the
+// queue is full the job stays pending and is picked up by the next sweep.
+func (s *exportService) enqueue(id uint) {
+	select {
+	case s.queue <- id:
+	default:
+	}
+}
+
+func (s *exportService) sweep() {
+	now := time.Now()
+	expired, err := s.exportRepo.GetExpired(now)
+	if err != nil {
+		log.Printf("export: failed to load expired jobs: %v", err)
+	}
+	for i := range expired {
+		job := &expired[i]
+		if err := os.Remove(job.FilePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("export: failed to remove %s: %v", job.FilePath, err)
+			continue
+		}
+		job.Status = domain.ExportStatusExpired
+		job.FilePath = ""
+		if err := s.exportRepo.Update(job); err != nil {
+			log.Printf("export: failed to expire job %d: %v", job.ID, err)
+		}
+	}
+
+	pending, err := s.exportRepo.GetPending()
+	if err != nil {
+		log.Printf("export: failed to load pending jobs: %v", err)
+		return
+	}
+	for _, job := range pending {
+		s.run(job.ID)
+	}
+}
+
+func (s *exportService) run(id uint) {
+	job, err := s.exportRepo.Claim(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	}
+	if err != nil {
+		log.Printf("export: failed to claim job %d: %v", id, err)
+		return
+	}
+
+	path, size, err := s.build(job)
+	now := time.Now()
+	if err != nil {
+		log.Printf("export: job %d failed: %v", job.ID, err)
+		job.Status = domain.ExportStatusFailed
+		job.Error = "failed to build export archive"
+	} else {
+		expiresAt := now.Add(time.Duration(s.cfg.ExportRetentionHours) * time.Hour)
+		job.Status = domain.ExportStatusCompleted
+		job.FilePath = path
+		job.FileSize = size
+		job.ExpiresAt = &expiresAt
+	}
+	job.CompletedAt = &now
+
+	if err := s.exportRepo.Update(job); err != nil {
+		log.Printf("export: failed to save job %d: %v", job.ID, err)
+	}
+}
+
+// build writes the archive to a temporary file first so a half-written zip
+// is never offered for download.
+func (s *exportService) build(job *domain.ExportJob) (string, int64, error) {
+	data, err := s.exportRepo.LoadUserData(job.UserID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := os.MkdirAll(s.cfg.ExportDir, 0o700); err != nil {
+		return "", 0, err
+	}
+
+	suffix, err := utils.GenerateRandomToken(12)
+	if err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(s.cfg.ExportDir, fmt.Sprintf("export-%d-%d-%s.zip", job.UserID, job.ID, suffix))
+
+	tmp, err := os.CreateTemp(s.cfg.ExportDir, "export-*.tmp")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := writeArchive(tmp, data); err != nil {
+		tmp.Close()
+		return "", 0, err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return "", 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, err
+	}
+
+	return path, info.Size(), nil
+}
+
+func writeArchive(f *os.File, data *domain.UserData) error {
+	zw := zip.NewWriter(f)
+
+	todos := make([]exportTodo, len(data.Todos))
+	for i, todo := range data.Todos {
+		todos[i] = newExportTodo(todo)
+	}
+	categories := make([]exportCategory, len(data.Categories))
+	for i, category := range data.Categories {
+		categories[i] = newExportCategory(category)
+	}
+
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", data.Profile},
+		{"categories.json", categories},
+		{"tags.json", data.Tags},
+		{"todos.json", todos},
+		{"dependencies.json", data.Dependencies},
+		{"sessions.json", data.Sessions},
+		{"access_tokens.json", data.AccessTokens},
+		{"external_identities.json", data.ExternalIdentities},
+	}
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.content); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// exportTodo and exportCategory add deleted_at, which the API hides, and
+// leave out the relations.
+type exportTodo struct {
+	ID          uint            `json:"id"`
+	CategoryID  *uint           `json:"category_id"`
+	ParentID    *uint           `json:"parent_id"`
+	Position    int             `json:"position"`
+	Rank        string          `json:"rank"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Deadline    *time.Time      `json:"deadline"`
+	Priority    domain.Priority `json:"priority"`
+	Status      domain.Status   `json:"status"`
+	Recurrence  string          `json:"recurrence"`
+	SeriesID    *uint           `json:"series_id"`
+	Occurrence  int             `json:"occurrence"`
+	TagIDs      []uint          `json:"tag_ids"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	DeletedAt   *time.Time      `json:"deleted_at"`
+}
+
+func newExportTodo(todo domain.Todo) exportTodo {
+	record := exportTodo{
+		ID:          todo.ID,
+		CategoryID:  todo.CategoryID,
+		ParentID:    todo.ParentID,
+		Position:    todo.Position,
+		Rank:        todo.Rank,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Deadline:    todo.Deadline,
+		Priority:    todo.Priority,
+		Status:      todo.Status,
+		Recurrence:  todo.Recurrence,
+		SeriesID:    todo.SeriesID,
+		Occurrence:  todo.Occurrence,
+		TagIDs:      tagIDs(todo.Tags),
+		CreatedAt:   todo.CreatedAt,
+		UpdatedAt:   todo.UpdatedAt,
+	}
+	if todo.DeletedAt.Valid {
+		record.DeletedAt = &todo.DeletedAt.Time
+	}
+	return record
+}
+
+type exportCategory struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Color       string     `json:"color"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+}
+
+func newExportCategory(category domain.Category) exportCategory {
+	record := exportCategory{
+		ID:          category.ID,
+		Name:        category.Name,
+		Description: category.Description,
+		Color:       category.Color,
+		CreatedAt:   category.CreatedAt,
+		UpdatedAt:   category.UpdatedAt,
+	}
+	if category.DeletedAt.Valid {
+		record.DeletedAt = &category.DeletedAt.Time
+	}
+	return record
+}
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/apperror"
+	"github.com/iskhakmuhamad/todo-api/internal/config"
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/repository"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrInvalidMFACode    = apperror.Forbidden("invalid_mfa_code", "invalid two-factor code")
+	ErrMFAAlreadyEnabled = apperror.Conflict("mfa_already_enabled", "two-factor authentication is already enabled")
+	ErrMFANotEnabled     = apperror.Conflict("mfa_not_enabled", "two-factor authentication is not enabled")
+	ErrMFANotStarted     = apperror.Conflict("mfa_not_started", "two-factor enrollment has not been started")
+)
+
+type MFAService interface {
+	Enroll(userID uint) (*domain.MFAEnrollResponse, error)
+	Confirm(userID uint, req domain.MFACodeRequest) ([]string, error)
+	Disable(userID uint, req domain.MFADisableRequest) error
+	RegenerateRecoveryCodes(userID uint, req domain.MFACodeRequest) ([]string, error)
+	VerifyCode(user *domain.User, code string) error
+}
+
+type mfaService struct {
+	userRepo         repository.UserRepository
+	recoveryCodeRepo repository.RecoveryCodeRepository
+	cfg              *config.Config
+}
+
+func NewMFAService(userRepo repository.UserRepository, recoveryCodeRepo repository.RecoveryCodeRepository, cfg *config.Config) MFAService {
+	return &mfaService{
+		userRepo:         userRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		cfg:              cfg,
+	}
+}
+
+// Enroll creates a new pending TOTP secret. Two-factor authentication is only
+// switched on once Confirm receives a valid code for it.
+func (s *mfaService) Enroll(userID uint) (*domain.MFAEnrollResponse, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+	if err := s.userRepo.Update(user, "totp_secret", "totp_last_step"); err != nil {
+		return nil, err
+	}
+
+	return &domain.MFAEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(s.cfg.MFAIssuer, user.Email, secret),
+	}, nil
+}
+
+func (s *mfaService) Confirm(userID uint, req domain.MFACodeRequest) ([]string, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrMFANotStarted
+	}
+
+	if err := s.verifyTOTP(user, req.Code); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user.TOTPEnabledAt = &now
+	if err := s.userRepo.Update(user, "totp_enabled_at"); err != nil {
+		return nil, err
+	}
+
+	return s.generateRecoveryCodes(user.ID)
+}
+
+func (s *mfaService) Disable(userID uint, req domain.MFADisableRequest) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if user.TOTPEnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	if err := s.VerifyCode(user, req.Code); err != nil {
+		return err
+	}
+
+	user.TOTPSecret = ""
+	user.TOTPEnabledAt = nil
+	user.TOTPLastStep = 0
+	if err := s.userRepo.Update(user, "totp_secret", "totp_enabled_at", "totp_last_step"); err != nil {
+		return err
+	}
+
+	return s.recoveryCodeRepo.DeleteForUser(user.ID)
+}
+
+func (s *mfaService) RegenerateRecoveryCodes(userID uint, req domain.MFACodeRequest) ([]string, error) {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TOTPEnabledAt == nil {
+		return nil, ErrMFANotEnabled
+	}
+
+	if err := s.verifyTOTP(user, req.Code); err != nil {
+		return nil, err
+	}
+
+	return s.generateRecoveryCodes(user.ID)
+}
+
+// VerifyCode accepts either a current TOTP code or an unused recovery code.
+func (s *mfaService) VerifyCode(user *domain.User, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == 6 {
+		return s.verifyTOTP(user, code)
+	}
+
+	used, err := s.recoveryCodeRepo.Consume(user.ID, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// verifyTOTP validates a TOTP code and records its time step so the same code
+// cannot be replayed within its validity window.
+func (s *mfaService) verifyTOTP(user *domain.User, code string) error {
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, strings.TrimSpace(code), time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return ErrInvalidMFACode
+	}
+
+	// Claim the step in the database, so two requests racing with the same
+	// code cannot both succeed
+	used, err := s.userRepo.UseTOTPStep(user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	user.TOTPLastStep = step
+	return nil
+}
+
+func (s *mfaService) generateRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		secret, err := utils.GenerateTOTPSecret()
+		if err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(secret[:5] + "-" + secret[5:10])
+		codes[i] = code
+		hashes[i] = utils.HashToken(normalizeRecoveryCode(code))
+	}
+
+	if err := s.recoveryCodeRepo.ReplaceForUser(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/apperror"
+	"github.com/iskhakmuhamad/todo-api/internal/config"
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/oidc"
+	"github.com/iskhakmuhamad/todo-api/internal/repository"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+var (
+	ErrOIDCNotConfigured = apperror.NotFound("oidc_not_configured", "single sign-on is not configured")
+
+	errInvalidOIDCState      = apperror.Unauthorized("invalid_oidc_state", "invalid or expired login state")
+	errOIDCExchangeFailed    = apperror.Unauthorized("oidc_exchange_failed", "could not redeem the authorization code")
+	errInvalidIDToken        = apperror.Unauthorized("invalid_id_token", "invalid id token")
+	errOIDCEmailUnverified   = apperror.Forbidden("oidc_email_unverified", "identity provider did not return a verified email address")
+	errOIDCAccountUnverified = apperror.Conflict("oidc_account_unverified", "an account with this email exists but has not verified it, sign in with its password and verify the email first")
+)
+
+var usernameCleaner = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+type OIDCService interface {
+	Begin(deviceName string) (*domain.OIDCLoginResponse, error)
+	Callback(code, state string, client domain.ClientInfo) (*domain.LoginResponse, error)
+}
+
+type oidcService struct {
+	provider     *oidc.Provider
+	userRepo     repository.UserRepository
+	identityRepo repository.IdentityRepository
+	authService  AuthService
+	cfg          *config.Config
+}
+
+func NewOIDCService(
+	userRepo repository.UserRepository,
+	identityRepo repository.IdentityRepository,
+	authService AuthService,
+	cfg *config.Config,
+) OIDCService {
+	var provider *oidc.Provider
+	if cfg.OIDCIssuerURL != "" {
+		provider = oidc.NewProvider(oidc.Config{
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+		})
+	}
+
+	return &oidcService{
+		provider:     provider,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		authService:  authService,
+		cfg:          cfg,
+	}
+}
+
+// Begin starts an authorization code flow with PKCE and returns the URL the
+// user has to visit at the identity provider.
+func (s *oidcService) Begin(deviceName string) (*domain.OIDCLoginResponse, error) {
+	if s.provider == nil {
+		return nil, ErrOIDCNotConfigured
+	}
+
+	state, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := s.provider.AuthCodeURL(state, nonce, oidc.CodeChallenge(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.identityRepo.CreateState(&domain.OAuthState{
+		StateHash:    utils.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		DeviceName:   deviceName,
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	}); err != nil {
+		return nil, err
+	}
+
+	return &domain.OIDCLoginResponse{
+		AuthorizationURL: authURL,
+		State:            state,
+	}, nil
+}
+
+// Callback finishes the flow: it redeems the code, verifies the ID token,
+// finds or provisions the local user and issues the usual tokens.
+func (s *oidcService) Callback(code, state string, client domain.ClientInfo) (*domain.LoginResponse, error) {
+	if s.provider == nil {
+		return nil, ErrOIDCNotConfigured
+	}
+	if code == "" || state == "" {
+		return nil, apperror.BadRequest("invalid_callback", "code and state are required")
+	}
+
+	saved, err := s.identityRepo.ConsumeState(utils.HashToken(state))
+	if err != nil {
+		return nil, notFound(err, errInvalidOIDCState)
+	}
+	if time.Now().After(saved.ExpiresAt) {
+		return nil, errInvalidOIDCState
+	}
+
+	token, err := s.provider.Exchange(code, saved.CodeVerifier)
+	if err != nil {
+		return nil, errOIDCExchangeFailed.Wrap(err)
+	}
+
+	claims, err := s.provider.VerifyIDToken(token.IDToken, saved.Nonce)
+	if err != nil {
+		return nil, errInvalidIDToken.Wrap(err)
+	}
+
+	user, err := s.resolveUser(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.authService.LoginWithIdentity(user, saved.DeviceName, client)
+}
+
+// resolveUser returns the user linked to the external identity. On first
+// login the identity is linked to the account with the same email if both
+// sides verified it, or a new account is provisioned. An account that never
+// verified its email could have been registered by anyone, so it is not
+// linked.
+func (s *oidcService) resolveUser(claims *oidc.IDTokenClaims) (*domain.User, error) {
+	identity, err := s.identityRepo.GetByProviderSubject(s.cfg.OIDCProvider, claims.Subject)
+	if err == nil {
+		return s.userRepo.GetByID(identity.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, errOIDCEmailUnverified
+	}
+
+	user, err := s.userRepo.GetByEmail(claims.Email)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if user, err = s.provisionUser(claims); err != nil {
+			return nil, err
+		}
+	} else if user.EmailVerifiedAt == nil {
+		return nil, errOIDCAccountUnverified
+	}
+
+	if err := s.identityRepo.Create(&domain.ExternalIdentity{
+		UserID:   user.ID,
+		Provider: s.cfg.OIDCProvider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *oidcService) provisionUser(claims *oidc.IDTokenClaims) (*domain.User, error) {
+	username, err := s.availableUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// The account gets a random password nobody knows. The user can set one
+	// through the forgot password flow if they ever need it.
+	randomPassword, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(randomPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := &domain.User{
+		Email:           claims.Email,
+		Username:        username,
+		Password:        string(hashedPassword),
+		Role:            domain.RoleUser,
+		EmailVerifiedAt: &now,
+	}
+
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *oidcService) availableUsername(claims *oidc.IDTokenClaims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	base = usernameCleaner.ReplaceAllString(base, "_")
+	if len(base) < 3 {
+		base = "user_" + base
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		_, err := s.userRepo.GetByUsername(candidate)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		suffix, err := utils.GenerateRandomToken(3)
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "_" + strings.ToLower(usernameCleaner.ReplaceAllString(suffix, ""))
+	}
+
+	return "", apperror.Conflict("username_unavailable", "could not find a free username")
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/iskhakmuhamad/todo-api/internal/apperror"
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/repository"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+const defaultTagColor = "#6B7280"
+
+type TagService interface {
+	Create(userID uint, req domain.CreateTagRequest) (*domain.Tag, error)
+	GetAll(userID uint) ([]domain.Tag, error)
+	GetByID(id, userID uint) (*domain.Tag, error)
+	Replace(id, userID, version uint, req domain.ReplaceTagRequest) (*domain.Tag, error)
+	Patch(id, userID, version uint, patch domain.TagPatch) (*domain.Tag, error)
+	Delete(id, userID, version uint) error
+	Merge(id, userID, version uint, req domain.MergeTagRequest) (*domain.MergeTagResult, error)
+}
+
+var ErrTagExists = apperror.Conflict("tag_exists", "a tag with this name already exists, merge the tags instead")
+
+type tagService struct {
+	tagRepo repository.TagRepository
+}
+
+func NewTagService(tagRepo repository.TagRepository) TagService {
+	return &tagService{tagRepo: tagRepo}
+}
+
+func (s *tagService) Create(userID uint, req domain.CreateTagRequest) (*domain.Tag, error) {
+	name := strings.TrimSpace(req.Name)
+	if err := s.checkName(userID, 0, name); err != nil {
+		return nil, err
+	}
+
+	tag := &domain.Tag{
+		UserID:  userID,
+		Name:    name,
+		Color:   req.Color,
+		Version: 1,
+	}
+	if tag.Color == "" {
+		tag.Color = defaultTagColor
+	}
+
+	if err := s.tagRepo.Create(tag); err != nil {
+		return nil, tagError(err, 0)
+	}
+	return tag, nil
+}
+
+func (s *tagService) GetAll(userID uint) ([]domain.Tag, error) {
+	return s.tagRepo.GetByUserID(userID)
+}
+
+func (s *tagService) GetByID(id, userID uint) (*domain.Tag, error) {
+	tag, err := s.tagRepo.GetByID(id, userID)
+	if err != nil {
+		return nil, notFound(err, ErrTagNotFound)
+	}
+	return tag, nil
+}
+
+// Replace overwrites the tag's name and color, as in a PUT.
+func (s *tagService) Replace(id, userID, version uint, req domain.ReplaceTagRequest) (*domain.Tag, error) {
+	tag, err := s.getForUpdate(id, userID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.save(tag, version, req)
+}
+
+// Patch applies a JSON merge patch and validates the resulting tag.
+func (s *tagService) Patch(id, userID, version uint, patch domain.TagPatch) (*domain.Tag, error) {
+	tag, err := s.getForUpdate(id, userID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	req := domain.ReplaceTagRequest{
+		Name:  tag.Name,
+		Color: tag.Color,
+	}
+	patch.Apply(&req)
+	if err := utils.Validate(req); err != nil {
+		return nil, err
+	}
+
+	return s.save(tag, version, req)
+}
+
+// save renames and recolors the tag. The new name shows up on every todo
+// with the tag; renaming to the name of another tag is refused, Merge is the
+// way to combine two tags.
+func (s *tagService) save(tag *domain.Tag, version uint, req domain.ReplaceTagRequest) (*domain.Tag, error) {
+	name := strings.TrimSpace(req.Name)
+	if err := s.checkName(tag.UserID, tag.ID, name); err != nil {
+		return nil, err
+	}
+	tag.Name = name
+	tag.Color = req.Color
+	if tag.Color == "" {
+		tag.Color = defaultTagColor
+	}
+
+	if err := s.tagRepo.Update(tag); err != nil {
+		return nil, tagError(err, version)
+	}
+	return tag, nil
+}
+
+// Delete removes the tag from all todos and deletes it.
+func (s *tagService) Delete(id, userID, version uint) error {
+	tag, err := s.getForUpdate(id, userID, version)
+	if err != nil {
+		return err
+	}
+
+	if err := s.tagRepo.Delete(tag); err != nil {
+		return staleVersion(err, version)
+	}
+	return nil
+}
+
+// Merge moves every todo tagged with the tag over to req.TargetID and
+// deletes the tag. version is the If-Match precondition on the tag being
+// merged away.
+func (s *tagService) Merge(id, userID, version uint, req domain.MergeTagRequest) (*domain.MergeTagResult, error) {
+	source, err := s.getForUpdate(id, userID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.TargetID == source.ID {
+		return nil, utils.NewFieldError("target_id", "nefield", "target_id must be a different tag")
+	}
+	target, err := s.tagRepo.GetByID(req.TargetID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewFieldError("target_id", "exists", "target_id does not refer to one of your tags")
+		}
+		return nil, err
+	}
+
+	affected, err := s.tagRepo.Merge(source, target)
+	if err != nil {
+		return nil, tagError(err, version)
+	}
+
+	return &domain.MergeTagResult{
+		Tag:           target,
+		TodosAffected: affected,
+	}, nil
+}
+
+// checkName makes sure no other tag of the user has the name, ignoring case.
+// id is the tag being renamed, 0 for a new one.
+func (s *tagService) checkName(userID, id uint, name string) error {
+	if name == "" {
+		return utils.NewFieldError("name", "required", "name is required")
+	}
+
+	existing, err := s.tagRepo.GetByName(name, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if existing.ID != id {
+		return ErrTagExists
+	}
+	return nil
+}
+
+// tagError maps a tag write failing on the unique name index, which catches
+// what checkName cannot see when two requests race for the same name.
+func tagError(err error, version uint) error {
+	if errors.Is(err, repository.ErrNameTaken) {
+		return ErrTagExists
+	}
+	return staleVersion(err, version)
+}
+
+func (s *tagService) getForUpdate(id, userID, version uint) (*domain.Tag, error) {
+	tag, err := s.GetByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(tag.Version, version); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
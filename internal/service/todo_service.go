@@ -1,27 +1,83 @@
 package service
 
 import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/apperror"
+	"github.com/iskhakmuhamad/todo-api/internal/config"
 	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/recurrence"
 	"github.com/iskhakmuhamad/todo-api/internal/repository"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
+
+	"gorm.io/gorm"
 )
 
 type TodoService interface {
 	Create(userID uint, req domain.CreateTodoRequest) (*domain.Todo, error)
 	GetAll(userID uint, filter domain.TodoFilter) ([]domain.Todo, int64, error)
 	GetByID(id, userID uint) (*domain.Todo, error)
-	Update(id, userID uint, req domain.UpdateTodoRequest) (*domain.Todo, error)
-	Delete(id, userID uint) error
+	Replace(id, userID, version uint, req domain.ReplaceTodoRequest) (*domain.Todo, error)
+	Patch(id, userID, version uint, patch domain.TodoPatch) (*domain.Todo, error)
+	ToggleStatus(id, userID, version uint) (*domain.Todo, error)
+	Delete(id, userID, version uint) error
+	Bulk(userID uint, req domain.BulkTodoRequest) (*domain.BulkTodoResponse, error)
+	CreateSubtask(parentID, userID uint, req domain.CreateTodoRequest) (*domain.Todo, error)
+	GetSubtasks(parentID, userID uint) ([]domain.Todo, error)
+	ReorderSubtasks(parentID, userID uint, req domain.ReorderSubtasksRequest) ([]domain.Todo, error)
+	Skip(id, userID, version uint) (*domain.Todo, error)
+	GetDependencies(id, userID uint) (*domain.TodoDependencies, error)
+	AddDependency(id, userID uint, req domain.AddDependencyRequest) (*domain.TodoDependencies, error)
+	RemoveDependency(id, blockerID, userID uint) error
+	Next(userID uint, page, limit int) ([]domain.Todo, int64, error)
+	Move(id, userID, version uint, req domain.MoveTodoRequest) (*domain.Todo, error)
 }
 
+var (
+	ErrNestedSubtask       = apperror.Validation("nested_subtask", "subtasks cannot have subtasks of their own")
+	ErrNotRecurring        = apperror.Conflict("not_recurring", "todo does not repeat")
+	ErrOccurrenceCompleted = apperror.Conflict("occurrence_completed", "a completed occurrence cannot be skipped")
+	ErrSeriesEnded         = apperror.Conflict("series_ended", "there is no later occurrence to skip to")
+	ErrDependencyCycle     = apperror.Conflict("dependency_cycle", "the blocker already waits for this todo, directly or through other todos")
+	ErrBlocked             = apperror.Conflict("todo_blocked", "todo cannot be done while a todo it waits for is still open")
+	ErrManualSortList      = apperror.BadRequest("manual_sort_without_list", "sort=manual needs category_id or parent_id")
+	ErrManualSortSubtasks  = apperror.BadRequest("manual_sort_with_subtasks", "sort=manual cannot include subtasks, list them with parent_id")
+
+	errRecurringSubtask = utils.NewFieldError("recurrence", "subtask", "a subtask cannot recur, set the recurrence on its parent instead")
+)
+
 type todoService struct {
-	todoRepo repository.TodoRepository
+	todoRepo     repository.TodoRepository
+	categoryRepo repository.CategoryRepository
+	tagRepo      repository.TagRepository
+	autoComplete bool
 }
 
-func NewTodoService(todoRepo repository.TodoRepository) TodoService {
-	return &todoService{todoRepo: todoRepo}
+func NewTodoService(todoRepo repository.TodoRepository, categoryRepo repository.CategoryRepository, tagRepo repository.TagRepository, cfg *config.Config) TodoService {
+	return &todoService{
+		todoRepo:     todoRepo,
+		categoryRepo: categoryRepo,
+		tagRepo:      tagRepo,
+		autoComplete: cfg.SubtaskAutoComplete,
+	}
 }
 
 func (s *todoService) Create(userID uint, req domain.CreateTodoRequest) (*domain.Todo, error) {
+	if err := s.checkCategory(userID, req.CategoryID); err != nil {
+		return nil, err
+	}
+	rule, err := checkRecurrence(req.Recurrence, req.Deadline)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.checkTags(userID, req.TagIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	todo := &domain.Todo{
 		UserID:      userID,
 		Title:       req.Title,
@@ -30,6 +86,10 @@ func (s *todoService) Create(userID uint, req domain.CreateTodoRequest) (*domain
 		Deadline:    req.Deadline,
 		Priority:    req.Priority,
 		Status:      domain.StatusTodo,
+		Recurrence:  rule,
+		Occurrence:  1,
+		Version:     1,
+		Tags:        tags,
 	}
 
 	if todo.Priority == "" {
@@ -44,6 +104,16 @@ func (s *todoService) Create(userID uint, req domain.CreateTodoRequest) (*domain
 }
 
 func (s *todoService) GetAll(userID uint, filter domain.TodoFilter) ([]domain.Todo, int64, error) {
+	// Ranks only order the todos of one list
+	if filter.Sort == domain.SortManual && filter.ParentID == 0 {
+		if filter.CategoryID == 0 {
+			return nil, 0, ErrManualSortList
+		}
+		if filter.IncludeSubtasks {
+			return nil, 0, ErrManualSortSubtasks
+		}
+	}
+
 	if filter.Page == 0 {
 		filter.Page = 1
 	}
@@ -55,41 +125,738 @@ func (s *todoService) GetAll(userID uint, filter domain.TodoFilter) ([]domain.To
 }
 
 func (s *todoService) GetByID(id, userID uint) (*domain.Todo, error) {
-	return s.todoRepo.GetByID(id, userID)
+	todo, err := s.todoRepo.GetByID(id, userID)
+	if err != nil {
+		return nil, notFound(err, ErrTodoNotFound)
+	}
+	return todo, nil
 }
 
-func (s *todoService) Update(id, userID uint, req domain.UpdateTodoRequest) (*domain.Todo, error) {
-	todo, err := s.todoRepo.GetByID(id, userID)
+// Replace overwrites every field of the todo, as in a PUT. version is the
+// If-Match precondition, 0 when there is none.
+func (s *todoService) Replace(id, userID, version uint, req domain.ReplaceTodoRequest) (*domain.Todo, error) {
+	todo, err := s.getForUpdate(id, userID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.save(todo, version, req)
+}
+
+// Patch applies a JSON merge patch. The result has to be a valid todo, so
+// e.g. clearing the title is rejected.
+func (s *todoService) Patch(id, userID, version uint, patch domain.TodoPatch) (*domain.Todo, error) {
+	todo, err := s.getForUpdate(id, userID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	req := replaceTodoRequest(todo)
+	patch.Apply(&req)
+	if err := utils.Validate(req); err != nil {
+		return nil, err
+	}
+
+	return s.save(todo, version, req)
+}
+
+func (s *todoService) ToggleStatus(id, userID, version uint) (*domain.Todo, error) {
+	todo, err := s.getForUpdate(id, userID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	req := replaceTodoRequest(todo)
+	req.Status = domain.StatusDone
+	if todo.Status == domain.StatusDone {
+		req.Status = domain.StatusTodo
+	}
+
+	return s.save(todo, version, req)
+}
+
+// Delete moves the todo to the trash. Its subtasks go with it.
+func (s *todoService) Delete(id, userID, version uint) error {
+	todo, err := s.getForUpdate(id, userID, version)
+	if err != nil {
+		return err
+	}
+	return s.todoRepo.Transaction(func(repo repository.TodoRepository) error {
+		if err := repo.Delete(todo); err != nil {
+			return staleVersion(err, version)
+		}
+		return s.syncParent(repo, todo.ParentID, userID)
+	})
+}
+
+func (s *todoService) getForUpdate(id, userID, version uint) (*domain.Todo, error) {
+	todo, err := s.GetByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(todo.Version, version); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// save writes the full state in req to the todo. Completing a todo also
+// completes its subtasks, and a subtask's status change may complete or
+// reopen its parent.
+func (s *todoService) save(todo *domain.Todo, version uint, req domain.ReplaceTodoRequest) (*domain.Todo, error) {
+	if err := s.checkCategory(todo.UserID, req.CategoryID); err != nil {
+		return nil, err
+	}
+
+	rule, err := checkRecurrence(req.Recurrence, req.Deadline)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.checkTags(todo.UserID, req.TagIDs)
+	if err != nil {
+		return nil, err
+	}
+	tagsChanged := !sameTags(todo.Tags, tags)
+
+	if rule != "" && rule != todo.Recurrence && todo.ParentID != nil {
+		return nil, errRecurringSubtask
+	}
+	if rule != todo.Recurrence {
+		// A new rule only applies from this occurrence on, so it starts a
+		// new series and earlier occurrences keep the old one
+		todo.SeriesID = nil
+		todo.Occurrence = 1
+	}
+
+	statusChanged := todo.Status != req.Status
+	if todo.ParentID == nil && !sameID(todo.CategoryID, req.CategoryID) {
+		// The todo goes to the top of its new category's manual order.
+		// Subtasks keep their position under the parent.
+		todo.Rank = ""
+	}
+	if statusChanged && req.Status == domain.StatusDone && len(todo.BlockedBy) > 0 {
+		return nil, ErrBlocked
+	}
+
+	todo.Title = req.Title
+	todo.Description = req.Description
+	todo.CategoryID = req.CategoryID
+	todo.Deadline = req.Deadline
+	todo.Priority = req.Priority
+	todo.Status = req.Status
+	todo.Recurrence = rule
+	todo.Tags = tags
+
+	// The todo, its tags, its subtasks, its parent and the next occurrence
+	// are written together or not at all
+	var next *domain.Todo
+	err = s.todoRepo.Transaction(func(repo repository.TodoRepository) error {
+		if err := repo.Update(todo); err != nil {
+			return staleVersion(err, version)
+		}
+		if tagsChanged {
+			if err := repo.SetTags(todo.ID, tagIDs(tags)); err != nil {
+				return err
+			}
+		}
+		if !statusChanged {
+			return nil
+		}
+
+		if todo.Status == domain.StatusDone {
+			var err error
+			if next, err = s.complete(repo, todo); err != nil {
+				return err
+			}
+		}
+		return s.syncParent(repo, todo.ParentID, todo.UserID)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if req.Title != "" {
-		todo.Title = req.Title
+	// Reload so the returned category and progress are current
+	saved, err := s.GetByID(todo.ID, todo.UserID)
+	if err != nil {
+		return nil, err
 	}
-	if req.Description != "" {
-		todo.Description = req.Description
+	saved.NextOccurrence = next
+	return saved, nil
+}
+
+// Skip moves a recurring todo on to its next occurrence without completing
+// it.
+func (s *todoService) Skip(id, userID, version uint) (*domain.Todo, error) {
+	todo, err := s.getForUpdate(id, userID, version)
+	if err != nil {
+		return nil, err
 	}
-	if req.CategoryID != nil {
-		todo.CategoryID = req.CategoryID
+	if todo.Recurrence == "" || todo.Deadline == nil {
+		return nil, ErrNotRecurring
 	}
-	if req.Deadline != nil {
-		todo.Deadline = req.Deadline
+	if todo.Status == domain.StatusDone {
+		return nil, ErrOccurrenceCompleted
 	}
-	if req.Priority != "" {
-		todo.Priority = req.Priority
+
+	rule, err := recurrence.Parse(todo.Recurrence)
+	if err != nil {
+		return nil, err
 	}
-	if req.Status != "" {
-		todo.Status = req.Status
+	next, ok := rule.Next(*todo.Deadline, todo.Occurrence)
+	if !ok {
+		return nil, ErrSeriesEnded
 	}
 
+	todo.Deadline = &next
+	todo.Occurrence++
 	if err := s.todoRepo.Update(todo); err != nil {
+		return nil, staleVersion(err, version)
+	}
+	return s.GetByID(todo.ID, todo.UserID)
+}
+
+// complete does what follows a todo being marked done, however that
+// happened: its open subtasks are completed with it, except those waiting for
+// an open todo, and a recurring todo gets its next occurrence, which is
+// returned.
+func (s *todoService) complete(repo repository.TodoRepository, todo *domain.Todo) (*domain.Todo, error) {
+	if todo.Progress != nil {
+		if _, err := repo.CompleteSubtasks(todo.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if todo.Recurrence == "" {
+		return nil, nil
+	}
+	return s.createNextOccurrence(repo, todo)
+}
+
+// createNextOccurrence creates the occurrence after the completed todo,
+// unless the series has ended or the next one already exists (e.g. when the
+// todo is reopened and completed again). Subtasks that recur from before
+// recurrence was refused on them get no next occurrence, which would reopen
+// their parent every time they are completed.
+func (s *todoService) createNextOccurrence(repo repository.TodoRepository, todo *domain.Todo) (*domain.Todo, error) {
+	if todo.Deadline == nil || todo.ParentID != nil {
+		return nil, nil
+	}
+	rule, err := recurrence.Parse(todo.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := rule.Next(*todo.Deadline, todo.Occurrence)
+	if !ok {
+		return nil, nil
+	}
+
+	seriesID := todo.ID
+	if todo.SeriesID != nil {
+		seriesID = *todo.SeriesID
+	}
+	exists, err := repo.HasOccurrence(seriesID, todo.Occurrence+1)
+	if err != nil || exists {
 		return nil, err
 	}
 
+	next := &domain.Todo{
+		UserID:      todo.UserID,
+		CategoryID:  todo.CategoryID,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Deadline:    &deadline,
+		Priority:    todo.Priority,
+		Status:      domain.StatusTodo,
+		Recurrence:  todo.Recurrence,
+		SeriesID:    &seriesID,
+		Occurrence:  todo.Occurrence + 1,
+		Version:     1,
+		Tags:        todo.Tags,
+	}
+	if err := repo.Create(next); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// checkRecurrence validates a recurrence rule and returns it in canonical
+// form. A recurring todo needs a deadline to count occurrences from.
+func checkRecurrence(rule string, deadline *time.Time) (string, error) {
+	if strings.TrimSpace(rule) == "" {
+		return "", nil
+	}
+
+	parsed, err := recurrence.Parse(rule)
+	if err != nil {
+		return "", utils.NewFieldError("recurrence", "rrule", "recurrence is invalid: "+err.Error())
+	}
+	if deadline == nil {
+		return "", utils.NewFieldError("deadline", "required", "deadline is required for a recurring todo")
+	}
+	return parsed.String(), nil
+}
+
+// Bulk applies one action to many todos in a single transaction. Requested
+// IDs that do not belong to the user are reported as not found, and todos
+// mark_done cannot complete yet as blocked, rather than failing the whole
+// request. Status changes are followed up as in save: mark_done completes
+// subtasks and creates next occurrences, and both status actions update the
+// parents of the subtasks they change.
+func (s *todoService) Bulk(userID uint, req domain.BulkTodoRequest) (*domain.BulkTodoResponse, error) {
+	switch {
+	case len(req.IDs) == 0 && req.Filter == nil:
+		return nil, utils.NewFieldError("ids", "required", "either ids or filter is required")
+	case len(req.IDs) > 0 && req.Filter != nil:
+		return nil, utils.NewFieldError("filter", "excluded_with", "ids and filter cannot be used together")
+	case req.Filter != nil && req.Filter.IsEmpty():
+		return nil, utils.NewFieldError("filter", "required", "filter must set at least one of status, priority, category_id, parent_id, keyword, blocked or a tag filter")
+	}
+
+	var updates map[string]interface{}
+	switch req.Action {
+	case domain.BulkMarkDone:
+		updates = map[string]interface{}{"status": domain.StatusDone}
+	case domain.BulkMarkUndone:
+		updates = map[string]interface{}{"status": domain.StatusTodo}
+	case domain.BulkSetPriority:
+		if req.Priority == "" {
+			return nil, utils.NewFieldError("priority", "required", "priority is required for set_priority")
+		}
+		updates = map[string]interface{}{"priority": req.Priority}
+	case domain.BulkMove:
+		if err := s.checkCategory(userID, req.CategoryID); err != nil {
+			return nil, err
+		}
+		updates = map[string]interface{}{"category_id": req.CategoryID, "rank": ""}
+	}
+
+	var matched, blocked []uint
+	result := domain.BulkResultUpdated
+	if req.Action == domain.BulkDelete {
+		result = domain.BulkResultDeleted
+	}
+	err := s.todoRepo.Transaction(func(repo repository.TodoRepository) error {
+		var err error
+		if req.Action == domain.BulkDelete {
+			matched, err = repo.BulkDelete(userID, req.IDs, req.Filter)
+			return err
+		}
+
+		matched, blocked, err = repo.BulkUpdate(userID, req.IDs, req.Filter, updates)
+		if err != nil || (req.Action != domain.BulkMarkDone && req.Action != domain.BulkMarkUndone) {
+			return err
+		}
+
+		todos, err := repo.GetByIDs(matched, userID)
+		if err != nil {
+			return err
+		}
+		if req.Action == domain.BulkMarkDone {
+			for i := range todos {
+				if _, err := s.complete(repo, &todos[i]); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Then bring each parent in line with its subtasks, once
+		synced := make(map[uint]bool)
+		for _, todo := range todos {
+			if todo.ParentID == nil || synced[*todo.ParentID] {
+				continue
+			}
+			synced[*todo.ParentID] = true
+			if err := s.syncParent(repo, todo.ParentID, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[uint]string, len(matched)+len(blocked))
+	for _, id := range matched {
+		results[id] = result
+	}
+	for _, id := range blocked {
+		results[id] = domain.BulkResultBlocked
+	}
+
+	ids := req.IDs
+	if req.Filter != nil {
+		ids = append(matched, blocked...)
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+
+	response := &domain.BulkTodoResponse{
+		Action:  req.Action,
+		Results: make([]domain.BulkTodoResult, 0, len(ids)),
+	}
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		outcome, ok := results[id]
+		if !ok {
+			outcome = domain.BulkResultNotFound
+		}
+		if outcome == result {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+		response.Results = append(response.Results, domain.BulkTodoResult{ID: id, Result: outcome})
+	}
+	return response, nil
+}
+
+// CreateSubtask adds a subtask at the end of the todo's list. It is filed
+// under the parent's category unless another one is given. Subtasks do not
+// recur: each occurrence would add an open subtask, and the parent could
+// never be completed by its subtasks.
+func (s *todoService) CreateSubtask(parentID, userID uint, req domain.CreateTodoRequest) (*domain.Todo, error) {
+	parent, err := s.GetByID(parentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if parent.ParentID != nil {
+		return nil, ErrNestedSubtask
+	}
+	if strings.TrimSpace(req.Recurrence) != "" {
+		return nil, errRecurringSubtask
+	}
+
+	if req.CategoryID == nil {
+		req.CategoryID = parent.CategoryID
+	}
+	if err := s.checkCategory(userID, req.CategoryID); err != nil {
+		return nil, err
+	}
+	tags, err := s.checkTags(userID, req.TagIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	todo := &domain.Todo{
+		UserID:      userID,
+		CategoryID:  req.CategoryID,
+		ParentID:    &parent.ID,
+		Title:       req.Title,
+		Description: req.Description,
+		Deadline:    req.Deadline,
+		Priority:    req.Priority,
+		Status:      domain.StatusTodo,
+		Occurrence:  1,
+		Version:     1,
+		Tags:        tags,
+	}
+	if todo.Priority == "" {
+		todo.Priority = domain.PriorityMedium
+	}
+
+	err = s.todoRepo.Transaction(func(repo repository.TodoRepository) error {
+		if err := repo.Create(todo); err != nil {
+			return err
+		}
+		// A new open subtask reopens an auto-completed parent
+		return s.syncParent(repo, todo.ParentID, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
 	return todo, nil
 }
 
-func (s *todoService) Delete(id, userID uint) error {
-	return s.todoRepo.Delete(id, userID)
+func (s *todoService) GetSubtasks(parentID, userID uint) ([]domain.Todo, error) {
+	if _, err := s.GetByID(parentID, userID); err != nil {
+		return nil, err
+	}
+	return s.todoRepo.GetSubtasks(parentID, userID)
+}
+
+// ReorderSubtasks puts the subtasks in the order of req.IDs, which has to
+// list every subtask exactly once.
+func (s *todoService) ReorderSubtasks(parentID, userID uint, req domain.ReorderSubtasksRequest) ([]domain.Todo, error) {
+	if _, err := s.GetByID(parentID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.todoRepo.ReorderSubtasks(parentID, req.IDs); err != nil {
+		if errors.Is(err, repository.ErrSubtasksChanged) {
+			return nil, utils.NewFieldError("ids", "subtasks", "ids must list every subtask of the todo exactly once")
+		}
+		return nil, err
+	}
+	return s.todoRepo.GetSubtasks(parentID, userID)
+}
+
+// syncParent completes the parent once all its subtasks are done, unless it
+// waits for an open todo, and reopens it when one is not, if auto-completion
+// is enabled.
+func (s *todoService) syncParent(repo repository.TodoRepository, parentID *uint, userID uint) error {
+	if !s.autoComplete || parentID == nil {
+		return nil
+	}
+
+	parent, err := repo.GetByID(*parentID, userID)
+	if err != nil {
+		return notFound(err, ErrTodoNotFound)
+	}
+	if parent.Progress == nil {
+		return nil
+	}
+
+	status := domain.StatusTodo
+	if parent.Progress.Done == parent.Progress.Total {
+		status = domain.StatusDone
+	}
+	if parent.Status == status {
+		return nil
+	}
+	if status == domain.StatusDone && len(parent.BlockedBy) > 0 {
+		// Like any todo, the parent waits for its blockers
+		return nil
+	}
+
+	parent.Status = status
+	err = repo.Update(parent)
+	if errors.Is(err, repository.ErrStaleVersion) {
+		// Someone else just changed the parent, their write wins
+		return nil
+	}
+	if err != nil || status != domain.StatusDone {
+		return err
+	}
+	_, err = s.complete(repo, parent)
+	return err
+}
+
+// GetDependencies lists the todos the todo waits for and the todos waiting
+// for it.
+func (s *todoService) GetDependencies(id, userID uint) (*domain.TodoDependencies, error) {
+	if _, err := s.GetByID(id, userID); err != nil {
+		return nil, err
+	}
+	return s.todoRepo.GetDependencies(id)
+}
+
+// AddDependency makes the todo wait for req.BlockerID. Dependencies that
+// would make a todo wait for itself are refused.
+func (s *todoService) AddDependency(id, userID uint, req domain.AddDependencyRequest) (*domain.TodoDependencies, error) {
+	if _, err := s.GetByID(id, userID); err != nil {
+		return nil, err
+	}
+
+	if req.BlockerID == id {
+		return nil, utils.NewFieldError("blocker_id", "nefield", "a todo cannot wait for itself")
+	}
+	if _, err := s.todoRepo.GetByID(req.BlockerID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewFieldError("blocker_id", "exists", "blocker_id does not refer to one of your todos")
+		}
+		return nil, err
+	}
+
+	if err := s.todoRepo.AddDependency(userID, id, req.BlockerID); err != nil {
+		if errors.Is(err, repository.ErrDependencyCycle) {
+			return nil, ErrDependencyCycle
+		}
+		return nil, err
+	}
+	return s.todoRepo.GetDependencies(id)
+}
+
+func (s *todoService) RemoveDependency(id, blockerID, userID uint) error {
+	if _, err := s.GetByID(id, userID); err != nil {
+		return err
+	}
+	if err := s.todoRepo.RemoveDependency(id, blockerID); err != nil {
+		return notFound(err, ErrDependencyNotFound)
+	}
+	return nil
+}
+
+// Next lists the user's open todos that wait for nothing, one page at a
+// time, highest priority first, then the earliest deadline. Todos waiting for
+// another are left out until their blockers are done, so the list does not
+// look further ahead in the dependency graph. total counts every actionable
+// todo.
+func (s *todoService) Next(userID uint, page, limit int) ([]domain.Todo, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+
+	todos, err := s.todoRepo.GetOpen(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	actionable := make([]domain.Todo, 0, len(todos))
+	for _, todo := range todos {
+		if len(todo.BlockedBy) == 0 {
+			actionable = append(actionable, todo)
+		}
+	}
+	sort.Slice(actionable, func(i, j int) bool { return comesFirst(actionable[i], actionable[j]) })
+
+	total := len(actionable)
+	offset := min((page-1)*limit, total)
+	end := min(offset+limit, total)
+	return actionable[offset:end], int64(total), nil
+}
+
+var priorityRank = map[domain.Priority]int{
+	domain.PriorityHigh:   0,
+	domain.PriorityMedium: 1,
+	domain.PriorityLow:    2,
+}
+
+// comesFirst orders actionable todos: by priority, then deadline (todos
+// without one last), then age.
+func comesFirst(a, b domain.Todo) bool {
+	if priorityRank[a.Priority] != priorityRank[b.Priority] {
+		return priorityRank[a.Priority] < priorityRank[b.Priority]
+	}
+	switch {
+	case a.Deadline != nil && b.Deadline == nil:
+		return true
+	case a.Deadline == nil && b.Deadline != nil:
+		return false
+	case a.Deadline != nil && !a.Deadline.Equal(*b.Deadline):
+		return a.Deadline.Before(*b.Deadline)
+	}
+	return a.ID < b.ID
+}
+
+// Move places the todo right before or after another todo of the same list
+// in the manual order: a subtask of the same parent, or a top-level todo of
+// the same category.
+func (s *todoService) Move(id, userID, version uint, req domain.MoveTodoRequest) (*domain.Todo, error) {
+	todo, err := s.getForUpdate(id, userID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	field, anchorID, after := "before_id", req.BeforeID, false
+	switch {
+	case req.BeforeID == nil && req.AfterID == nil:
+		return nil, utils.NewFieldError("before_id", "required", "either before_id or after_id is required")
+	case req.BeforeID != nil && req.AfterID != nil:
+		return nil, utils.NewFieldError("after_id", "excluded_with", "before_id and after_id cannot be used together")
+	case req.AfterID != nil:
+		field, anchorID, after = "after_id", req.AfterID, true
+	}
+
+	if *anchorID == id {
+		return nil, utils.NewFieldError(field, "nefield", "a todo cannot be moved next to itself")
+	}
+	anchor, err := s.todoRepo.GetByID(*anchorID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, utils.NewFieldError(field, "exists", field+" does not refer to one of your todos")
+		}
+		return nil, err
+	}
+	switch {
+	case todo.ParentID != nil || anchor.ParentID != nil:
+		if !sameID(anchor.ParentID, todo.ParentID) {
+			return nil, utils.NewFieldError(field, "same_parent", field+" must refer to a subtask of the same todo")
+		}
+	case !sameID(anchor.CategoryID, todo.CategoryID):
+		return nil, utils.NewFieldError(field, "same_category", field+" must refer to a todo in the same category")
+	}
+
+	if err := s.todoRepo.Move(todo, anchor.ID, after); err != nil {
+		return nil, staleVersion(err, version)
+	}
+	return s.GetByID(id, userID)
+}
+
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// replaceTodoRequest describes the todo's current state, the base a merge
+// patch is applied to.
+func replaceTodoRequest(todo *domain.Todo) domain.ReplaceTodoRequest {
+	return domain.ReplaceTodoRequest{
+		Title:       todo.Title,
+		Description: todo.Description,
+		CategoryID:  todo.CategoryID,
+		Deadline:    todo.Deadline,
+		Priority:    todo.Priority,
+		Status:      todo.Status,
+		Recurrence:  todo.Recurrence,
+		TagIDs:      tagIDs(todo.Tags),
+	}
+}
+
+// checkCategory makes sure a todo can only be filed under one of the user's
+// own categories.
+func (s *todoService) checkCategory(userID uint, categoryID *uint) error {
+	if categoryID == nil {
+		return nil
+	}
+
+	if _, err := s.categoryRepo.GetByID(*categoryID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return utils.NewFieldError("category_id", "exists", "category_id does not refer to one of your categories")
+		}
+		return err
+	}
+	return nil
+}
+
+// checkTags makes sure a todo is only tagged with the user's own tags, and
+// returns them.
+func (s *todoService) checkTags(userID uint, ids []uint) ([]domain.Tag, error) {
+	unique := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+
+	tags, err := s.tagRepo.GetByIDs(ids, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) != len(unique) {
+		return nil, utils.NewFieldError("tag_ids", "exists", "tag_ids must only refer to your own tags")
+	}
+	return tags, nil
+}
+
+func tagIDs(tags []domain.Tag) []uint {
+	ids := make([]uint, len(tags))
+	for i, tag := range tags {
+		ids[i] = tag.ID
+	}
+	return ids
+}
+
+// sameTags reports whether both lists hold the same tags, in any order.
+func sameTags(a, b []domain.Tag) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ids := make(map[uint]bool, len(a))
+	for _, tag := range a {
+		ids[tag.ID] = true
+	}
+	for _, tag := range b {
+		if !ids[tag.ID] {
+			return false
+		}
+	}
+	return true
 }
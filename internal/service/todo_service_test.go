@@ -0,0 +1,320 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/repository"
+	"github.com/iskhakmuhamad/todo-api/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+// fakeTodoRepo keeps todos in memory for the service logic that does not
+// depend on SQL. Methods a test does not need panic through the nil
+// embedded interface.
+type fakeTodoRepo struct {
+	repository.TodoRepository
+	todos   map[uint]*domain.Todo
+	created []*domain.Todo
+}
+
+func newFakeTodoRepo(todos ...domain.Todo) *fakeTodoRepo {
+	repo := &fakeTodoRepo{todos: make(map[uint]*domain.Todo)}
+	for i := range todos {
+		todo := todos[i]
+		if todo.Version == 0 {
+			todo.Version = 1
+		}
+		repo.todos[todo.ID] = &todo
+	}
+	return repo
+}
+
+func (r *fakeTodoRepo) GetByID(id, userID uint) (*domain.Todo, error) {
+	stored, ok := r.todos[id]
+	if !ok || stored.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	todo := *stored
+	todo.Progress = nil
+	for _, other := range r.todos {
+		if other.ParentID == nil || *other.ParentID != id {
+			continue
+		}
+		if todo.Progress == nil {
+			todo.Progress = &domain.TodoProgress{}
+		}
+		todo.Progress.Total++
+		if other.Status == domain.StatusDone {
+			todo.Progress.Done++
+		}
+	}
+	return &todo, nil
+}
+
+func (r *fakeTodoRepo) Create(todo *domain.Todo) error {
+	todo.ID = uint(len(r.todos) + 1)
+	stored := *todo
+	r.todos[todo.ID] = &stored
+	r.created = append(r.created, &stored)
+	return nil
+}
+
+func (r *fakeTodoRepo) Update(todo *domain.Todo) error {
+	stored, ok := r.todos[todo.ID]
+	if !ok || stored.Version != todo.Version {
+		return repository.ErrStaleVersion
+	}
+	todo.Version++
+	updated := *todo
+	r.todos[todo.ID] = &updated
+	return nil
+}
+
+func (r *fakeTodoRepo) CompleteSubtasks(parentID uint) ([]uint, error) {
+	var ids []uint
+	for _, todo := range r.todos {
+		if todo.ParentID != nil && *todo.ParentID == parentID && todo.Status != domain.StatusDone {
+			todo.Status = domain.StatusDone
+			ids = append(ids, todo.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *fakeTodoRepo) HasOccurrence(seriesID uint, occurrence int) (bool, error) {
+	for _, todo := range r.todos {
+		if todo.SeriesID != nil && *todo.SeriesID == seriesID && todo.Occurrence == occurrence {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeTodoRepo) Transaction(fn func(repo repository.TodoRepository) error) error {
+	return fn(r)
+}
+
+type fakeTagRepo struct {
+	repository.TagRepository
+}
+
+func (fakeTagRepo) GetByIDs(ids []uint, userID uint) ([]domain.Tag, error) {
+	return nil, nil
+}
+
+func newTestTodoService(repo *fakeTodoRepo) *todoService {
+	return &todoService{todoRepo: repo, tagRepo: fakeTagRepo{}, autoComplete: true}
+}
+
+func isFieldError(err error, field string) bool {
+	var verr *utils.ValidationError
+	return errors.As(err, &verr) && len(verr.Fields) == 1 && verr.Fields[0].Field == field
+}
+
+func TestSubtaskRecurrence(t *testing.T) {
+	deadline := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	parentID := uint(1)
+
+	t.Run("new subtasks cannot recur", func(t *testing.T) {
+		repo := newFakeTodoRepo(domain.Todo{ID: 1, UserID: 1, Title: "parent", Status: domain.StatusTodo})
+		_, err := newTestTodoService(repo).CreateSubtask(1, 1, domain.CreateTodoRequest{
+			Title:      "daily",
+			Deadline:   &deadline,
+			Recurrence: "FREQ=DAILY",
+		})
+		if !isFieldError(err, "recurrence") {
+			t.Fatalf("CreateSubtask() error = %v, want a recurrence field error", err)
+		}
+		if len(repo.created) != 0 {
+			t.Errorf("created %d todos, want none", len(repo.created))
+		}
+	})
+
+	t.Run("subtasks cannot be made recurring", func(t *testing.T) {
+		repo := newFakeTodoRepo(
+			domain.Todo{ID: 1, UserID: 1, Title: "parent", Status: domain.StatusTodo},
+			domain.Todo{ID: 2, UserID: 1, ParentID: &parentID, Title: "sub", Priority: domain.PriorityMedium, Status: domain.StatusTodo},
+		)
+		_, err := newTestTodoService(repo).Replace(2, 1, 1, domain.ReplaceTodoRequest{
+			Title:      "sub",
+			Deadline:   &deadline,
+			Priority:   domain.PriorityMedium,
+			Status:     domain.StatusTodo,
+			Recurrence: "FREQ=DAILY",
+		})
+		if !isFieldError(err, "recurrence") {
+			t.Fatalf("Replace() error = %v, want a recurrence field error", err)
+		}
+	})
+
+	t.Run("completing a recurring subtask completes its parent", func(t *testing.T) {
+		// Subtasks created before recurrence was refused on them
+		repo := newFakeTodoRepo(
+			domain.Todo{ID: 1, UserID: 1, Title: "parent", Priority: domain.PriorityMedium, Status: domain.StatusTodo},
+			domain.Todo{ID: 2, UserID: 1, ParentID: &parentID, Title: "sub", Priority: domain.PriorityMedium, Status: domain.StatusTodo,
+				Deadline: &deadline, Recurrence: "FREQ=DAILY", Occurrence: 1},
+		)
+		saved, err := newTestTodoService(repo).ToggleStatus(2, 1, 1)
+		if err != nil {
+			t.Fatalf("ToggleStatus() error = %v", err)
+		}
+		if saved.NextOccurrence != nil || len(repo.created) != 0 {
+			t.Errorf("created %d todos, want no next occurrence", len(repo.created))
+		}
+		if parent := repo.todos[1]; parent.Status != domain.StatusDone {
+			t.Errorf("parent status = %q, want %q", parent.Status, domain.StatusDone)
+		}
+	})
+
+	t.Run("completing a parent leaves its recurring subtasks done", func(t *testing.T) {
+		repo := newFakeTodoRepo(
+			domain.Todo{ID: 1, UserID: 1, Title: "parent", Priority: domain.PriorityMedium, Status: domain.StatusTodo},
+			domain.Todo{ID: 2, UserID: 1, ParentID: &parentID, Title: "sub", Priority: domain.PriorityMedium, Status: domain.StatusTodo,
+				Deadline: &deadline, Recurrence: "FREQ=DAILY", Occurrence: 1},
+		)
+		if _, err := newTestTodoService(repo).ToggleStatus(1, 1, 1); err != nil {
+			t.Fatalf("ToggleStatus() error = %v", err)
+		}
+		if len(repo.created) != 0 {
+			t.Errorf("created %d todos, want no next occurrence", len(repo.created))
+		}
+		if parent := repo.todos[1]; parent.Status != domain.StatusDone {
+			t.Errorf("parent status = %q, want %q", parent.Status, domain.StatusDone)
+		}
+	})
+}
+
+func (r *fakeTodoRepo) GetOpen(userID uint) ([]domain.Todo, error) {
+	var todos []domain.Todo
+	for _, todo := range r.todos {
+		if todo.UserID == userID && todo.Status != domain.StatusDone {
+			todos = append(todos, *todo)
+		}
+	}
+	return todos, nil
+}
+
+func TestNext(t *testing.T) {
+	soon := time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)
+	later := soon.AddDate(0, 0, 7)
+	repo := newFakeTodoRepo(
+		domain.Todo{ID: 1, UserID: 1, Priority: domain.PriorityLow, Status: domain.StatusTodo},
+		domain.Todo{ID: 2, UserID: 1, Priority: domain.PriorityHigh, Status: domain.StatusTodo, Deadline: &later},
+		domain.Todo{ID: 3, UserID: 1, Priority: domain.PriorityHigh, Status: domain.StatusTodo, Deadline: &soon},
+		domain.Todo{ID: 4, UserID: 1, Priority: domain.PriorityHigh, Status: domain.StatusTodo, BlockedBy: []uint{1}},
+		domain.Todo{ID: 5, UserID: 1, Priority: domain.PriorityMedium, Status: domain.StatusTodo},
+		domain.Todo{ID: 6, UserID: 1, Priority: domain.PriorityHigh, Status: domain.StatusDone},
+		domain.Todo{ID: 7, UserID: 1, Priority: domain.PriorityMedium, Status: domain.StatusTodo},
+		domain.Todo{ID: 8, UserID: 2, Priority: domain.PriorityHigh, Status: domain.StatusTodo},
+	)
+	svc := newTestTodoService(repo)
+
+	tests := []struct {
+		name        string
+		page, limit int
+		want        []uint
+	}{
+		{name: "priority, then deadline, then age", page: 1, limit: 10, want: []uint{3, 2, 5, 7, 1}},
+		{name: "first page", page: 1, limit: 2, want: []uint{3, 2}},
+		{name: "last page", page: 3, limit: 2, want: []uint{1}},
+		{name: "past the end", page: 4, limit: 2, want: []uint{}},
+		{name: "defaults", page: 0, limit: 0, want: []uint{3, 2, 5, 7, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todos, total, err := svc.Next(1, tt.page, tt.limit)
+			if err != nil {
+				t.Fatalf("Next() error = %v", err)
+			}
+			if total != 5 {
+				t.Errorf("total = %d, want 5", total)
+			}
+			got := make([]uint, len(todos))
+			for i, todo := range todos {
+				got[i] = todo.ID
+			}
+			if !sameIDs(got, tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func sameIDs(a, b []uint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// cycleRepo refuses every dependency as the database does one that closes a
+// cycle.
+type cycleRepo struct {
+	*fakeTodoRepo
+	err error
+}
+
+func (r cycleRepo) AddDependency(userID, todoID, blockerID uint) error {
+	return r.err
+}
+
+func (r cycleRepo) GetDependencies(todoID uint) (*domain.TodoDependencies, error) {
+	return &domain.TodoDependencies{}, nil
+}
+
+func TestAddDependencyCycle(t *testing.T) {
+	tests := []struct {
+		name      string
+		blockerID uint
+		repoErr   error
+		check     func(error) bool
+	}{
+		{
+			name:      "waiting for itself",
+			blockerID: 1,
+			check:     func(err error) bool { return isFieldError(err, "blocker_id") },
+		},
+		{
+			name:      "cycle through other todos",
+			blockerID: 2,
+			repoErr:   repository.ErrDependencyCycle,
+			check:     func(err error) bool { return errors.Is(err, ErrDependencyCycle) },
+		},
+		{
+			name:      "blocker of another user",
+			blockerID: 3,
+			check:     func(err error) bool { return isFieldError(err, "blocker_id") },
+		},
+		{
+			name:      "no cycle",
+			blockerID: 2,
+			check:     func(err error) bool { return err == nil },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := cycleRepo{
+				fakeTodoRepo: newFakeTodoRepo(
+					domain.Todo{ID: 1, UserID: 1, Status: domain.StatusTodo},
+					domain.Todo{ID: 2, UserID: 1, Status: domain.StatusTodo},
+					domain.Todo{ID: 3, UserID: 2, Status: domain.StatusTodo},
+				),
+				err: tt.repoErr,
+			}
+			svc := &todoService{todoRepo: repo, tagRepo: fakeTagRepo{}}
+			_, err := svc.AddDependency(1, 1, domain.AddDependencyRequest{BlockerID: tt.blockerID})
+			if !tt.check(err) {
+				t.Errorf("AddDependency() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/iskhakmuhamad/todo-api/internal/apperror"
+	"github.com/iskhakmuhamad/todo-api/internal/config"
+	"github.com/iskhakmuhamad/todo-api/internal/domain"
+	"github.com/iskhakmuhamad/todo-api/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// trashSweepInterval is how often items past the retention period are
+// purged.
+const trashSweepInterval = time.Hour
+
+var (
+	ErrParentInTrash   = apperror.Conflict("parent_in_trash", "restore the parent todo first")
+	ErrCategoryInTrash = apperror.Conflict("category_in_trash", "restore the todo's category first")
+)
+
+type TrashService interface {
+	GetTodos(userID uint) ([]domain.DeletedTodo, error)
+	GetCategories(userID uint) ([]domain.DeletedCategory, error)
+	RestoreTodo(id, userID uint) (*domain.Todo, error)
+	RestoreCategory(id, userID uint) (*domain.Category, error)
+	PurgeTodo(id, userID uint) error
+	PurgeCategory(id, userID uint) error
+	Start()
+}
+
+type trashService struct {
+	todoRepo     repository.TodoRepository
+	categoryRepo repository.CategoryRepository
+	retention    time.Duration
+}
+
+func NewTrashService(todoRepo repository.TodoRepository, categoryRepo repository.CategoryRepository, cfg *config.Config) TrashService {
+	return &trashService{
+		todoRepo:     todoRepo,
+		categoryRepo: categoryRepo,
+		retention:    time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour,
+	}
+}
+
+func (s *trashService) GetTodos(userID uint) ([]domain.DeletedTodo, error) {
+	todos, err := s.todoRepo.GetDeleted(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]domain.DeletedTodo, len(todos))
+	for i, todo := range todos {
+		deleted[i] = domain.DeletedTodo{
+			Todo:      todo,
+			DeletedAt: todo.DeletedAt.Time,
+			PurgeAt:   s.purgeAt(todo.DeletedAt.Time),
+		}
+	}
+	return deleted, nil
+}
+
+func (s *trashService) GetCategories(userID uint) ([]domain.DeletedCategory, error) {
+	categories, err := s.categoryRepo.GetDeleted(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]domain.DeletedCategory, len(categories))
+	for i, category := range categories {
+		deleted[i] = domain.DeletedCategory{
+			Category:  category,
+			DeletedAt: category.DeletedAt.Time,
+			PurgeAt:   s.purgeAt(category.DeletedAt.Time),
+		}
+	}
+	return deleted, nil
+}
+
+// RestoreTodo takes a todo out of the trash, with the subtasks deleted along
+// with it. A subtask cannot be restored while its parent is in the trash, nor
+// a todo while its category is.
+func (s *trashService) RestoreTodo(id, userID uint) (*domain.Todo, error) {
+	deleted, err := s.todoRepo.GetDeletedByID(id, userID)
+	if err != nil {
+		return nil, notFound(err, ErrTodoNotFound)
+	}
+	if deleted.ParentID != nil {
+		if _, err := s.todoRepo.GetByID(*deleted.ParentID, userID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrParentInTrash
+			}
+			return nil, err
+		}
+	}
+
+	if err := s.todoRepo.Restore(id, userID); err != nil {
+		if errors.Is(err, repository.ErrCategoryInTrash) {
+			return nil, ErrCategoryInTrash
+		}
+		return nil, notFound(err, ErrTodoNotFound)
+	}
+
+	todo, err := s.todoRepo.GetByID(id, userID)
+	if err != nil {
+		return nil, notFound(err, ErrTodoNotFound)
+	}
+	return todo, nil
+}
+
+func (s *trashService) RestoreCategory(id, userID uint) (*domain.Category, error) {
+	if err := s.categoryRepo.Restore(id, userID); err != nil {
+		return nil, notFound(err, ErrCategoryNotFound)
+	}
+
+	category, err := s.categoryRepo.GetByID(id, userID)
+	if err != nil {
+		return nil, notFound(err, ErrCategoryNotFound)
+	}
+	return category, nil
+}
+
+func (s *trashService) PurgeTodo(id, userID uint) error {
+	return notFound(s.todoRepo.Purge(id, userID), ErrTodoNotFound)
+}
+
+func (s *trashService) PurgeCategory(id, userID uint) error {
+	return notFound(s.categoryRepo.Purge(id, userID), ErrCategoryNotFound)
+}
+
+// Start purges items that have been in the trash longer than the retention
+// period, in the background. A retention of 0 keeps them forever.
+func (s *trashService) Start() {
+	if s.retention <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(trashSweepInterval)
+		defer ticker.Stop()
+
+		for ; ; <-ticker.C {
+			s.sweep()
+		}
+	}()
+}
+
+func (s *trashService) sweep() {
+	before := time.Now().Add(-s.retention)
+
+	if _, err := s.todoRepo.PurgeDeletedBefore(before); err != nil {
+		log.Printf("trash: failed to purge todos: %v", err)
+	}
+	if _, err := s.categoryRepo.PurgeDeletedBefore(before); err != nil {
+		log.Printf("trash: failed to purge categories: %v", err)
+	}
+}
+
+func (s *trashService) purgeAt(deletedAt time.Time) *time.Time {
+	if s.retention <= 0 {
+		return nil
+	}
+	purgeAt := deletedAt.Add(s.retention)
+	return &purgeAt
+}
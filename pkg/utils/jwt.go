@@ -2,36 +2,80 @@ package utils
 
 import (
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// PurposeMFA marks the short-lived token handed out after the password step
+// of a two-factor login. It is not accepted as an access token.
+const PurposeMFA = "mfa"
+
+// mfaAudienceSuffix is appended to the audience of MFA tokens, so anything
+// verifying access tokens against the published keys and the access token
+// audience rejects them too.
+const mfaAudienceSuffix = ":mfa"
+
 type JWTClaims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    uint   `json:"user_id"`
+	SessionID uint   `json:"sid"`
+	Email     string `json:"email"`
+	Role      string `json:"role,omitempty"`
+	Purpose   string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID uint, email, secret string, expireHours int) (string, error) {
+func GenerateJWT(userID, sessionID uint, email, role string, keys *KeySet, expiresIn time.Duration) (string, error) {
 	claims := JWTClaims{
-		UserID: userID,
-		Email:  email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(expireHours))),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+		UserID:           userID,
+		SessionID:        sessionID,
+		Email:            email,
+		Role:             role,
+		RegisteredClaims: registeredClaims(userID, keys.Issuer(), keys.Audience(), expiresIn),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return keys.Sign(claims)
 }
 
-func ValidateJWT(tokenString, secret string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+func GenerateMFAToken(userID uint, email string, keys *KeySet, expiresIn time.Duration) (string, error) {
+	claims := JWTClaims{
+		UserID:           userID,
+		Email:            email,
+		Purpose:          PurposeMFA,
+		RegisteredClaims: registeredClaims(userID, keys.Issuer(), keys.Audience()+mfaAudienceSuffix, expiresIn),
+	}
+
+	return keys.Sign(claims)
+}
 
+// ValidateJWT accepts access tokens only. MFA tokens are rejected by their
+// audience as well as their purpose.
+func ValidateJWT(tokenString string, keys *KeySet) (*JWTClaims, error) {
+	claims, err := parseClaims(tokenString, keys, keys.Audience())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// ValidateMFAToken accepts MFA tokens only.
+func ValidateMFAToken(tokenString string, keys *KeySet) (*JWTClaims, error) {
+	claims, err := parseClaims(tokenString, keys, keys.Audience()+mfaAudienceSuffix)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != PurposeMFA {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+func parseClaims(tokenString string, keys *KeySet, audience string) (*JWTClaims, error) {
+	token, err := keys.Parse(tokenString, &JWTClaims{}, audience)
 	if err != nil {
 		return nil, err
 	}
@@ -42,3 +86,13 @@ func ValidateJWT(tokenString, secret string) (*JWTClaims, error) {
 
 	return nil, errors.New("invalid token")
 }
+
+func registeredClaims(userID uint, issuer, audience string, expiresIn time.Duration) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Issuer:    issuer,
+		Subject:   strconv.FormatUint(uint64(userID), 10),
+		Audience:  jwt.ClaimStrings{audience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+}
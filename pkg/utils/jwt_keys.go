@@ -0,0 +1,255 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeySetConfig describes how tokens are signed. With HS256 the shared Secret
+// is used. With RS256 or EdDSA, KeyFiles maps key IDs to PEM files; each file
+// holds a private key (sign and verify) or a public key (verify only, for
+// keys being rotated out). ActiveKeyID selects the key new tokens are signed
+// with and defaults to the first private key listed.
+type KeySetConfig struct {
+	Algorithm   string
+	Secret      string
+	KeyFiles    []KeyFile
+	ActiveKeyID string
+	Issuer      string
+	Audience    string
+}
+
+type KeyFile struct {
+	ID   string
+	Path string
+}
+
+type signingKey struct {
+	id      string
+	private interface{}
+	public  interface{}
+}
+
+// KeySet signs and verifies JWTs. Every token carries the ID of the key it was
+// signed with in its kid header, so several keys can be valid at once while
+// keys are rotated.
+type KeySet struct {
+	method   jwt.SigningMethod
+	active   *signingKey
+	keys     map[string]*signingKey
+	issuer   string
+	audience string
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func LoadKeySet(cfg KeySetConfig) (*KeySet, error) {
+	ks := &KeySet{
+		keys:     make(map[string]*signingKey),
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+	}
+
+	switch strings.ToUpper(cfg.Algorithm) {
+	case "", "HS256":
+		if cfg.Secret == "" {
+			return nil, errors.New("JWT secret is required for HS256")
+		}
+		ks.method = jwt.SigningMethodHS256
+		ks.active = &signingKey{id: "default", private: []byte(cfg.Secret), public: []byte(cfg.Secret)}
+		ks.keys[ks.active.id] = ks.active
+		return ks, nil
+	case "RS256":
+		ks.method = jwt.SigningMethodRS256
+	case "EDDSA":
+		ks.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.Algorithm)
+	}
+
+	if len(cfg.KeyFiles) == 0 {
+		return nil, fmt.Errorf("%s requires at least one key in JWT_KEYS", ks.method.Alg())
+	}
+
+	for _, file := range cfg.KeyFiles {
+		key, err := loadKeyFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkKeyType(ks.method, key.public); err != nil {
+			return nil, fmt.Errorf("key %q: %w", file.ID, err)
+		}
+		ks.keys[key.id] = key
+
+		if ks.active == nil && key.private != nil && cfg.ActiveKeyID == "" {
+			ks.active = key
+		}
+	}
+
+	if cfg.ActiveKeyID != "" {
+		ks.active = ks.keys[cfg.ActiveKeyID]
+	}
+	if ks.active == nil || ks.active.private == nil {
+		return nil, errors.New("no private key available to sign JWTs")
+	}
+
+	return ks, nil
+}
+
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.method, claims)
+	token.Header["kid"] = ks.active.id
+	return token.SignedString(ks.active.private)
+}
+
+// Parse verifies the signature with the key named by the kid header and
+// checks expiry, issuer and that the token is meant for audience.
+func (ks *KeySet) Parse(tokenString string, claims jwt.Claims, audience string) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = ks.active.id
+		}
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key.public, nil
+	},
+		jwt.WithValidMethods([]string{ks.method.Alg()}),
+		jwt.WithIssuer(ks.issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+	)
+}
+
+func (ks *KeySet) Issuer() string {
+	return ks.issuer
+}
+
+func (ks *KeySet) Audience() string {
+	return ks.audience
+}
+
+// JWKS returns the public keys for publishing. It is empty for HS256, where
+// there is no public key to share.
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range ks.keys {
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.id,
+				Use: "sig",
+				Alg: ks.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.id,
+				Use: "sig",
+				Alg: ks.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return set
+}
+
+// ParseKeyFiles parses "kid=path,kid=path" as used by the JWT_KEYS setting.
+func ParseKeyFiles(value string) ([]KeyFile, error) {
+	var files []KeyFile
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, path, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || path == "" {
+			return nil, fmt.Errorf("invalid JWT key entry %q, expected kid=path", entry)
+		}
+		files = append(files, KeyFile{ID: strings.TrimSpace(id), Path: strings.TrimSpace(path)})
+	}
+	return files, nil
+}
+
+func loadKeyFile(file KeyFile) (*signingKey, error) {
+	data, err := os.ReadFile(file.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %q: no PEM data found in %s", file.ID, file.Path)
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY", "RSA PRIVATE KEY":
+		var private interface{}
+		if block.Type == "RSA PRIVATE KEY" {
+			private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		} else {
+			private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", file.ID, err)
+		}
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("key %q: unsupported private key type", file.ID)
+		}
+		return &signingKey{id: file.ID, private: private, public: signer.Public()}, nil
+	case "PUBLIC KEY":
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", file.ID, err)
+		}
+		return &signingKey{id: file.ID, public: public}, nil
+	}
+
+	return nil, fmt.Errorf("key %q: unsupported PEM block %q", file.ID, block.Type)
+}
+
+func checkKeyType(method jwt.SigningMethod, public interface{}) error {
+	switch public.(type) {
+	case *rsa.PublicKey:
+		if method == jwt.SigningMethodRS256 {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if method == jwt.SigningMethodEdDSA {
+			return nil
+		}
+	}
+	return fmt.Errorf("key type %T cannot be used with %s", public, method.Alg())
+}